@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+)
+
+/*
+ * Agents that occupy a square of k x k cells instead of a point on the
+ * grid corners or a single cell. The node of such an agent is its top-left
+ * corner in the corner model and its top-left cell in the cell-center
+ * model, like in Harabor and Botea's annotated A*. The searches do not
+ * change: isOpen only opens the cells where the agent fits, so every
+ * algorithm and lineOfSight move the agent like a point or a one-cell
+ * agent through its configuration space. Only the moves along grid edges
+ * of the corner model ask whether the agent fits at their corners.
+ */
+
+// The width of the agent in cells, 0 for points on grid corners and
+// one-cell agents in the cell-center model
+var agentSize = 0
+
+// trueClearance[y][x] is the width of the largest open square whose
+// top-left cell is (x,y), 0 for blocked cells. nil until it is needed,
+// and again after every grid change.
+var trueClearance [][]int
+
+func MustParseAgentSize(value string) int {
+	size := MustParseInt(value)
+	if size < 0 {
+		fmt.Println("The agent size must be at least 0.")
+		os.Exit(1)
+	}
+	return size
+}
+
+/*
+ * Computes the true clearance of every cell from the bottom-right corner
+ * of the map: an open square fits at a cell if squares one cell smaller
+ * fit at its neighbours to the east, south and south-east. Cells outside
+ * the map count as blocked.
+ */
+func computeTrueClearance() [][]int {
+	h := len(grid)
+	w := len(grid[0])
+	clearance := make([][]int, h)
+	for y := range(clearance) {
+		clearance[y] = make([]int, w)
+	}
+	at := func(x, y int) int {
+		if x >= w || y >= h {
+			return 0
+		}
+		return clearance[y][x]
+	}
+	for y := h-1; y >= 0; y-- {
+		for x := w-1; x >= 0; x-- {
+			if grid[y][x] {
+				continue
+			}
+			smallest := at(x+1, y)
+			for _, c := range([]int{at(x, y+1), at(x+1, y+1)}) {
+				if c < smallest {
+					smallest = c
+				}
+			}
+			clearance[y][x] = 1 + smallest
+		}
+	}
+	return clearance
+}
+
+/*
+ * The true clearance that an open cell needs so that the agent fits there
+ * in the given node model. A point in cell (x,y) of the corner model
+ * stands for the agent with its top-left corner anywhere inside that
+ * cell, where the agent covers parts of the cells up to x+k and y+k, so
+ * lines and diagonal moves that cross the cell need one cell more than
+ * the agent. Along the grid edges the agent only needs to fit at the
+ * corners, see agentFitsAtCorner, so it passes gaps as wide as itself.
+ */
+func agentClearanceNeeded(model NodeModel) int {
+	if model == GridCorners {
+		return agentSize + 1
+	}
+	return agentSize
+}
+
+// Whether the agent fits at the open cell (x,y)
+func agentFits(x, y int, model NodeModel) bool {
+	if agentSize == 0 {
+		return true
+	}
+	if trueClearance == nil {
+		trueClearance = computeTrueClearance()
+	}
+	return trueClearance[y][x] >= agentClearanceNeeded(model)
+}
+
+// Whether the agent of the corner model fits with its top-left corner at
+// grid corner (x,y), where it covers the cells (x,y) to (x+k-1,y+k-1)
+func agentFitsAtCorner(x, y int) bool {
+	if !isCellOpen(x, y) {
+		return false
+	}
+	if trueClearance == nil {
+		trueClearance = computeTrueClearance()
+	}
+	return trueClearance[y][x] >= agentSize
+}
+
+/*
+ * Whether the agent of the corner model may move its top-left corner
+ * along the horizontal or vertical line from a to b. Inside a row or
+ * column of cells the line crosses them, which needs cellOpen. Along a
+ * grid line the agent sweeps the cells of its squares at both corners of
+ * every grid edge that it passes, so it must fit at those corners.
+ */
+func agentLineClear(a, b Point, cellOpen func(int, int) bool) bool {
+	from, to, at := math.Min(a.X, b.X), math.Max(a.X, b.X), a.Y
+	open := cellOpen
+	fits := agentFitsAtCorner
+	// Swap the axes of vertical lines
+	if a.X == b.X {
+		from, to, at = math.Min(a.Y, b.Y), math.Max(a.Y, b.Y), a.X
+		open = func(x, y int) bool {
+			return cellOpen(y, x)
+		}
+		fits = func(x, y int) bool {
+			return agentFitsAtCorner(y, x)
+		}
+	}
+	for c := int(math.Floor(from)); float64(c) < to; c++ {
+		if float64(c+1) <= from {
+			continue
+		}
+		if at == math.Floor(at) {
+			if !fits(c, int(at)) || !fits(c+1, int(at)) {
+				return false
+			}
+		} else if !open(c, int(math.Floor(at))) {
+			return false
+		}
+	}
+	return true
+}
+
+// The corners of the agent at a point of a path, clockwise. In the
+// cell-center model the point is the center of its top-left cell.
+func agentCorners(p Point) []Point {
+	offset := 0.0
+	if nodeModel == CellCenters {
+		offset = 0.5
+	}
+	size   := float64(agentSize)
+	x, y   := p.X - offset, p.Y - offset
+	return []Point{{x, y}, {x + size, y}, {x + size, y + size}, {x, y + size}}
+}
+
+/*
+ * The area that the agent sweeps along a path: one convex polygon for each
+ * piece of the path, the convex hull of the agent at both ends of it.
+ */
+func agentFootprint(path []Point) [][]Point {
+	if agentSize == 0 || len(path) == 0 {
+		return [][]Point{}
+	}
+	if len(path) == 1 {
+		return [][]Point{agentCorners(path[0])}
+	}
+	footprint := [][]Point{}
+	for i := 0; i+1 < len(path); i++ {
+		corners := append(agentCorners(path[i]), agentCorners(path[i+1])...)
+		footprint = append(footprint, convexHull(corners))
+	}
+	return footprint
+}
+
+/*
+ * Convex hull in clockwise order on the map, where y grows downwards
+ * (Andrew's monotone chain).
+ */
+func convexHull(points []Point) []Point {
+	sorted := append([]Point{}, points...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].X != sorted[j].X {
+			return sorted[i].X < sorted[j].X
+		}
+		return sorted[i].Y < sorted[j].Y
+	})
+	cross := func(o, a, b Point) float64 {
+		return (a.X - o.X)*(b.Y - o.Y) - (a.Y - o.Y)*(b.X - o.X)
+	}
+	hull := []Point{}
+	// The lower chain from left to right, then the upper one back
+	for pass := 0; pass < 2; pass++ {
+		chainStart := len(hull)
+		for _, p := range(sorted) {
+			for len(hull) >= chainStart+2 && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+				hull = hull[:len(hull)-1]
+			}
+			hull = append(hull, p)
+		}
+		hull = hull[:len(hull)-1] // The last point starts the other chain
+		for i, j := 0, len(sorted)-1; i < j; i, j = i+1, j-1 {
+			sorted[i], sorted[j] = sorted[j], sorted[i]
+		}
+	}
+	return hull
+}
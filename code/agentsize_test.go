@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+/*
+ * Checks the agent sizes on random maps of up to 16x16 cells. The true
+ * clearance of every cell is compared with the squares that fit there,
+ * and the paths of A* and Theta* between random nodes are followed in
+ * every node model and connectivity: in the corner model the square that
+ * the agent sweeps along every piece of a path may not enter a blocked
+ * cell or leave the map, and in the cell-center model every cell of the
+ * agent must be open at every node of a path.
+ */
+func TestAgentSize(t *testing.T) {
+	keepGlobals(t)
+	squareOpen := func(x, y, size int) bool {
+		for dy := 0; dy < size; dy++ {
+			for dx := 0; dx < size; dx++ {
+				if !isCellOpen(x+dx, y+dy) {
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		g := randomGrid(random, 16, 16, 1.0/3)
+		agentSize = 0
+		setGrid(g)
+		clearance := computeTrueClearance()
+		for y := range(g) {
+			for x := range(g[y]) {
+				c := clearance[y][x]
+				if (c > 0 && !squareOpen(x, y, c)) || squareOpen(x, y, c+1) {
+					t.Errorf("map %d: cell (%d,%d) has true clearance %d\n%s", i, x, y, c, gridString(g))
+				}
+			}
+		}
+
+		for size := 1; size <= 3; size++ {
+			for _, model := range(allNodeModels) {
+				for _, c := range(allConnectivities) {
+					agentSize, nodeModel, connectivity = size, model, c
+					setGrid(g)
+					maxX, maxY := maxNodeCoordinates()
+					for j := 0; j < 10; j++ {
+						start := NewNode(random.Intn(maxX+1), random.Intn(maxY+1))
+						goal  := NewNode(random.Intn(maxX+1), random.Intn(maxY+1))
+						if start == goal {
+							continue // The searches do not look at the cells then
+						}
+						for _, name := range([]string{"astar", "thetastar"}) {
+							path := MustParsePathfindingFunction(name)(start, goal)
+							problem := ""
+							for k, p := range(path) {
+								if model == CellCenters && !squareOpen(int(p.X), int(p.Y), size) {
+									problem = fmt.Sprintf("the agent does not fit at (%g,%g)", p.X, p.Y)
+									break
+								}
+								if model != GridCorners || k+1 == len(path) {
+									continue
+								}
+								// The agent overlaps cell (x,y) when its top-left corner
+								// is less than size cells up and to the left of it
+								q := path[k+1]
+								for y := int(math.Min(p.Y, q.Y)) - 1; y <= int(math.Max(p.Y, q.Y)) + size; y++ {
+									for x := int(math.Min(p.X, q.X)) - 1; x <= int(math.Max(p.X, q.X)) + size; x++ {
+										low  := Point{float64(x - size), float64(y - size)}
+										high := Point{float64(x + 1),    float64(y + 1)}
+										if !isCellOpen(x, y) && segmentEntersRect(p, q, low, high) {
+											problem = fmt.Sprintf("the agent enters cell (%d,%d) from (%g,%g) to (%g,%g)", x, y, p.X, p.Y, q.X, q.Y)
+										}
+									}
+								}
+							}
+							if problem != "" {
+								t.Errorf("map %d, agent size %d, %s model, %s-connectivity: %s path (%d,%d) -> (%d,%d): %s\n%s",
+									i, size, nodeModelName(model), connectivityName(c), name, start.X, start.Y, goal.X, goal.Y, problem, gridString(g))
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+/*
+ * Compares getTraversableNodes with moveAllowedSpec and lineOfSight with
+ * pointsVisibleReference for agents of 1 to 3 cells on random maps of up
+ * to 10x10 cells, in every node model and connectivity.
+ */
+func TestAgentMoves(t *testing.T) {
+	keepGlobals(t)
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		g := randomGrid(random, 10, 10, 0.3)
+		for size := 1; size <= 3; size++ {
+			for _, model := range(allNodeModels) {
+				for _, c := range(allConnectivities) {
+					agentSize, nodeModel, connectivity = size, model, c
+					setGrid(g)
+					prefix := fmt.Sprintf("map %d, agent size %d, %s model, %s-connectivity", i, size, nodeModelName(model), connectivityName(c))
+					testNeighboursOfGrid(t, prefix)
+					maxX, maxY := maxNodeCoordinates()
+					for j := 0; j < 20; j++ {
+						start  := NewNode(random.Intn(maxX+1), random.Intn(maxY+1))
+						end    := NewNode(random.Intn(maxX+1), random.Intn(maxY+1))
+						points := NodesToPoints([]Node{start, end})
+						if start == end {
+							continue
+						}
+						got      := lineOfSight(start, end)
+						expected := pointsVisibleReference(points[0], points[1], isOpen)
+						if got != expected {
+							t.Errorf("%s: line of sight (%d,%d) -> (%d,%d) is %t, expected %t\n%s",
+								prefix, start.X, start.Y, end.X, end.Y, got, expected, gridString(g))
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// An agent of k cells passes a corridor of k cells but not one cell less,
+// in both node models
+func TestAgentCorridor(t *testing.T) {
+	keepGlobals(t)
+	for size := 1; size <= 3; size++ {
+		for width := size-1; width <= size; width++ {
+			// A horizontal corridor of the width through a blocked map
+			g := newGrid(12, 8, true)
+			for y := 2; y < 2 + width; y++ {
+				for x := range(g[y]) {
+					g[y][x] = false
+				}
+			}
+			for _, model := range(allNodeModels) {
+				for _, c := range(allConnectivities) {
+					agentSize, nodeModel, connectivity = size, model, c
+					setGrid(g)
+					start, goal := NewNode(0, 2), NewNode(12 - size, 2)
+					for _, name := range([]string{"astar", "thetastar"}) {
+						path := MustParsePathfindingFunction(name)(start, goal)
+						if (len(path) > 0) != (width == size) {
+							t.Errorf("agent size %d, %s model, %s-connectivity: %s finds a path of %d point(s) through a corridor of %d cell(s)",
+								size, nodeModelName(model), connectivityName(c), name, len(path), width)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// The true clearance of a small map by hand
+func TestTrueClearance(t *testing.T) {
+	keepGlobals(t)
+	setGrid(gridFromRows(
+		"....",
+		".@..",
+		"....",
+	))
+	expected := [][]int{{1, 1, 2, 1}, {1, 0, 2, 1}, {1, 1, 1, 1}}
+	if got := computeTrueClearance(); fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("the true clearance is %v, expected %v", got, expected)
+	}
+}
+
+/*
+ * A wall with a gap of one cell at its left end and of two cells at its
+ * right end, between cell centers. A one-cell agent goes straight down
+ * through the near gap, and an agent of two cells must take the far one.
+ */
+func TestAgentGap(t *testing.T) {
+	keepGlobals(t)
+	nodeModel, connectivity = CellCenters, EightConnectedNoCornerCutting
+	g := gridFromRows(
+		"..........",
+		"..........",
+		".@@@@@@@..",
+		"..........",
+		"..........",
+	)
+	start, goal := NewNode(0, 0), NewNode(0, 3)
+	for size := 1; size <= 2; size++ {
+		agentSize = size
+		setGrid(g)
+		path := AStar(start, goal)
+		through := NewNode(0, 2)
+		if size == 2 {
+			through = NewNode(8, 2)
+		}
+		found := false
+		for _, n := range(path) {
+			found = found || n == through
+		}
+		if !found {
+			t.Errorf("agent size %d: the path %v does not pass (%d,%d)", size, path, through.X, through.Y)
+		}
+	}
+}
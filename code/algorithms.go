@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+type OptimalityClass int
+const (
+	OctileOptimal OptimalityClass = iota // Shortest paths along the moves of the grid
+	AnyAngle                             // Paths may leave the moves of the grid and be shorter
+	Suboptimal                           // Not always the shortest paths of either kind
+)
+
+func (c OptimalityClass) String() string {
+	return [...]string{"octile-optimal", "any-angle", "suboptimal"}[c]
+}
+
+// How an algorithm gets from one point of its paths to the next
+type PathKind int
+const (
+	Moves     PathKind = iota // Moves to neighbouring nodes
+	Lines                     // Straight lines with line of sight
+	FreeLines                 // Straight lines through open cells with 8-connectivity, whatever the connectivity and node model
+)
+
+/*
+ * A pathfinding algorithm and what the modes need to know about it. Find
+ * returns the path in map coordinates, or an empty path if there is none.
+ * Preprocess may be nil, otherwise it runs on every map before the first
+ * query and gets the path of the map file. Options lists the options that
+ * change what the algorithm does, besides --connectivity, --model and
+ * --smooth.
+ */
+type Algorithm struct {
+	Name        string
+	Description string
+	Optimality  OptimalityClass
+	Path        PathKind
+	Options     []string
+	Find        func(Node, Node) []Point
+	Preprocess  func(string)
+}
+
+// The registered algorithms in the order of the help text
+var algorithms []Algorithm
+
+// Options that every algorithm follows
+var globalOptions = []string{"connectivity", "model", "smooth", "agent-size", "morphology"}
+
+/*
+ * Adds an algorithm to the ones that the modes accept. Panics if the name
+ * is taken, so that one algorithm cannot hide another. pathy is a single
+ * main package, which no other package can import, so algorithms cannot
+ * be registered from outside of it: in-house algorithms go into their own
+ * file in the code directory and register themselves from an init
+ * function. Registering from other packages needs the registry in a
+ * package of its own, which needs a Go module for its import path.
+ */
+func RegisterAlgorithm(a Algorithm) {
+	a.Name = strings.ToLower(a.Name)
+	if _, found := lookupAlgorithm(a.Name); found {
+		panic(fmt.Sprintf("Assertion failed: algorithm \"%s\" registered twice", a.Name))
+	}
+	if a.Find == nil {
+		panic(fmt.Sprintf("Assertion failed: algorithm \"%s\" has no Find function", a.Name))
+	}
+	algorithms = append(algorithms, a)
+}
+
+func lookupAlgorithm(name string) (Algorithm, bool) {
+	for _, a := range(algorithms) {
+		if a.Name == strings.ToLower(name) {
+			return a, true
+		}
+	}
+	return Algorithm{}, false
+}
+
+func algorithmNames() []string {
+	names := []string{}
+	for _, a := range(algorithms) {
+		names = append(names, a.Name)
+	}
+	return names
+}
+
+// Looks up an algorithm with the optimality class that the options give it
+func MustParseAlgorithm(name string) Algorithm {
+	a, found := lookupAlgorithm(name)
+	if !found {
+		fmt.Printf("Unknown algorithm \"%s\", accepted algorithms are \"%s\"\n", name, strings.Join(algorithmNames(), "\", \""))
+		os.Exit(1)
+	}
+	// Weighted A* trades the shortest paths for speed
+	if a.Optimality == OctileOptimal && heuristicWeight > 1 && a.supports("weight") {
+		a.Optimality = Suboptimal
+	}
+	return a
+}
+
+func MustParsePathfindingFunction(name string) func(Node, Node) []Point {
+	return MustParseAlgorithm(name).Find
+}
+
+func (a Algorithm) supports(option string) bool {
+	for _, o := range(a.Options) {
+		if o == option {
+			return true
+		}
+	}
+	// The seed only matters to the random tie-breaking
+	if option == "tie-breaking-seed" && tieBreaking == RandomTies {
+		return a.supports("tie-breaking")
+	}
+	// The landmark heuristic follows the landmark options
+	if (option == "landmarks" || option == "landmark-count") && selectedHeuristic == "landmark" {
+		return a.supports("heuristic")
+	}
+	return false
+}
+
+func printAlgorithmsHelp() {
+	fmt.Println("Accepted algorithms are:")
+	for _, a := range(algorithms) {
+		options := ""
+		if len(a.Options) > 0 {
+			options = ", options --" + strings.Join(a.Options, ", --")
+		}
+		fmt.Printf("    %s: %s (%s%s)\n", a.Name, a.Description, a.Optimality, options)
+	}
+}
+
+/*
+ * Whether the algorithm follows a global option. Free lines cross the
+ * cells in any direction, so they cannot follow --connectivity.
+ */
+func (a Algorithm) follows(option string) bool {
+	return option != "connectivity" || a.Path != FreeLines
+}
+
+// Prints a note for every option that was given but that the algorithm
+// does not use, and stops at global options that it cannot follow
+func warnAboutOptions(a Algorithm) {
+	for name := range(options) {
+		isGlobal := false
+		for _, global := range(globalOptions) {
+			isGlobal = isGlobal || name == global
+		}
+		if isGlobal && !a.follows(name) {
+			fmt.Printf("%s cannot follow --%s\n", a.Name, name)
+			os.Exit(1)
+		}
+		if !isGlobal && !a.supports(name) {
+			fmt.Printf("Note: %s does not use --%s\n", a.Name, name)
+		}
+	}
+}
+
+func init() {
+	heuristicOptions := []string{"heuristic"}
+	builtin := []Algorithm{
+		{"dijkstra", "Dijkstra's algorithm", OctileOptimal, Moves, []string{"tie-breaking"}, withPoints(Dijkstra), nil},
+		{"astar", "A*", OctileOptimal, Moves, []string{"heuristic", "weight", "tie-breaking"}, withPoints(AStar), nil},
+		{"astar-ps", "A* with post-smoothing", Suboptimal, Lines, []string{"heuristic", "weight", "tie-breaking"}, withPoints(AStarPs), nil},
+		{"thetastar", "Theta*, any-angle A*", AnyAngle, Lines, []string{"heuristic", "tie-breaking"}, withPoints(ThetaStar), nil},
+		{"arastar", "anytime repairing A*", Suboptimal, Moves, []string{"heuristic", "weight", "budget", "tie-breaking"}, withPoints(ARAStar), nil},
+		{"bidijkstra", "bidirectional Dijkstra", OctileOptimal, Moves, []string{"tie-breaking"}, withPoints(BidirectionalDijkstra), nil},
+		{"biastar", "bidirectional A*", OctileOptimal, Moves, []string{"heuristic", "tie-breaking"}, withPoints(BidirectionalAStar), nil},
+		{"idastar", "iterative deepening A*, only for short paths", OctileOptimal, Moves, heuristicOptions, withPoints(IDAStar), nil},
+		{"fringe", "Fringe Search", OctileOptimal, Moves, heuristicOptions, withPoints(FringeSearch), nil},
+		{"dstarlite", "D* Lite, planning from scratch", OctileOptimal, Moves, heuristicOptions, withPoints(DStarLiteSearch), nil},
+		{"fielddstar", "Field D*, any-angle with cell costs", AnyAngle, FreeLines, []string{"costs"}, FieldDStar, nil},
+		{"hpastar", "hierarchical A* on clusters", Suboptimal, Moves, []string{"heuristic", "cluster-size", "hpa-cache"}, withPoints(HPAStar), preprocessHPA},
+		{"subgoal", "simple subgoal graph", OctileOptimal, Moves, heuristicOptions, withPoints(SubgoalSearch), preprocessSubgoals},
+		{"alt", "A* with landmarks", OctileOptimal, Moves, []string{"landmarks", "landmark-count", "weight", "tie-breaking"}, withPoints(ALTAStar), preprocessLandmarks},
+	}
+	for _, a := range(builtin) {
+		RegisterAlgorithm(a)
+	}
+}
+
+// Wraps an algorithm that returns nodes into one that returns points
+func withPoints(algo func(Node, Node) []Node) func(Node, Node) []Point {
+	return func(start, goal Node) []Point {
+		return NodesToPoints(algo(start, goal))
+	}
+}
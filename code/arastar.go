@@ -0,0 +1,111 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// ARA* searches with a decreasing heuristic weight, starting from
+// araInitialWeight unless --weight gives a larger one.
+const araInitialWeight = 3.0
+const araWeightStep    = 0.5
+
+var araBudget = time.Second // Time after which the best solution so far is returned
+
+// One of the progressively better solutions of an anytime algorithm
+type AnytimeSolution struct {
+	Path   []Node
+	Weight float64       // Heuristic weight, the solution is at most this many times longer than optimal
+	Length float64
+	Time   time.Duration // Time since the search started
+}
+
+// The solutions found by the last call of ARAStar, in the order they were found
+var anytimeSolutions []AnytimeSolution
+
+/*
+ * Anytime Repairing A* (Likhachev, Gordon and Thrun 2003). Runs weighted
+ * A* searches with a decreasing weight, and every search reuses the work
+ * of the previous one: nodes whose cost improved after they were closed
+ * are kept in a list of inconsistent nodes and opened again for the next
+ * search. Stops after the search with weight 1, which is optimal, or when
+ * the time budget runs out. The first solution is always searched to the
+ * end so that a path is returned whenever one exists.
+ */
+func ARAStar(start, goal Node) []Node {
+	anytimeSolutions = []AnytimeSolution{}
+	if !sameComponent(start, goal) {
+		return []Node{}
+	}
+	resetPathfindingStructures()
+	heuristic = searchHeuristic(gridHeuristic)
+	begin    := time.Now()
+	weight   := math.Max(araInitialWeight, heuristicWeight)
+	incons   := map[Node]bool{}
+
+	g[start]    = 0
+	f[start]    = weight * heuristic(start, goal)
+	open[start] = true
+
+	for {
+		if !araImprovePath(goal, weight, incons, begin) {
+			break // Out of time
+		}
+		if math.IsInf(gScore(goal), 1) {
+			return []Node{}
+		}
+		anytimeSolutions = append(anytimeSolutions, AnytimeSolution{reconstructPath(start, goal), weight, g[goal], time.Since(begin)})
+		if weight <= 1 {
+			break
+		}
+
+		// Open the inconsistent nodes again and update the f scores of
+		// the open nodes for the next weight
+		weight = math.Max(1, weight - araWeightStep)
+		for node := range(incons) {
+			open[node] = true
+		}
+		incons = map[Node]bool{}
+		for node := range(open) {
+			f[node] = g[node] + weight * heuristic(node, goal)
+		}
+		closed = map[Node]bool{}
+	}
+	// The search may have improved the path after the last solution, but
+	// without a known bound
+	return anytimeSolutions[len(anytimeSolutions)-1].Path
+}
+
+/*
+ * Expands nodes until the goal has the lowest f score, which makes the
+ * path to it at most weight times longer than optimal. Returns false if
+ * the time budget ran out, which only happens after the first solution.
+ */
+func araImprovePath(goal Node, weight float64, incons map[Node]bool, begin time.Time) bool {
+	for len(open) > 0 {
+		node := openNodeWithLowestF()
+		if gScore(goal) <= f[node] {
+			return true
+		}
+		if len(anytimeSolutions) > 0 && time.Since(begin) > araBudget {
+			return false
+		}
+		delete(open, node)
+		closed[node] = true // Value doesn't matter
+		for _, neighbour := range(getTraversableNodes(node)) {
+			tentativeG := g[node] + costToNeighbour(node, neighbour)
+			if tentativeG < gScore(neighbour) {
+				parent[neighbour] = node
+				g[neighbour]      = tentativeG
+				if closed[neighbour] {
+					incons[neighbour] = true
+				} else {
+					f[neighbour]    = g[neighbour] + weight * heuristic(neighbour, goal)
+					open[neighbour] = true // Value doesn't matter
+					timestampNode(neighbour)
+				}
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+/*
+ * Runs ARA* between random nodes on random maps of up to 24x24 cells, in
+ * every node model and connectivity. With enough time the weights of the
+ * solutions go down to 1, every solution is at most its weight times
+ * longer than Dijkstra's path and the last one is as short. Without time
+ * the first solution is still returned.
+ */
+func TestARAStar(t *testing.T) {
+	keepGlobals(t)
+	oldBudget := araBudget
+	t.Cleanup(func() {
+		araBudget = oldBudget
+	})
+	const epsilon = 1e-6
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		g := randomGrid(random, 24, 24, 0.4)
+		for _, model := range(allNodeModels) {
+			for _, c := range(allConnectivities) {
+				nodeModel, connectivity = model, c
+				setGrid(g)
+				start    := randomNode(random)
+				goal     := randomNode(random)
+				expected := Dijkstra(start, goal)
+				prefix   := fmt.Sprintf("map %d, %s model, %s-connectivity: ARA* path (%d,%d) -> (%d,%d)",
+					i, nodeModelName(model), connectivityName(c), start.X, start.Y, goal.X, goal.Y)
+
+				araBudget = time.Minute
+				path := ARAStar(start, goal)
+				if problem := pathProblem(path, start, goal); problem != "" {
+					t.Errorf("%s: %s\n%s", prefix, problem, gridString(g))
+				}
+				if (len(path) > 0) != (len(expected) > 0) || math.Abs(PathLength(path) - PathLength(expected)) > epsilon {
+					t.Errorf("%s has length %f, expected %f\n%s", prefix, PathLength(path), PathLength(expected), gridString(g))
+				}
+				for k, s := range(anytimeSolutions) {
+					if (k > 0 && s.Weight >= anytimeSolutions[k-1].Weight) || s.Length > s.Weight * PathLength(expected) + epsilon {
+						t.Errorf("%s: solution %d has weight %g and length %f, the shortest length is %f\n%s",
+							prefix, k, s.Weight, s.Length, PathLength(expected), gridString(g))
+					}
+				}
+				if len(path) > 0 && anytimeSolutions[len(anytimeSolutions)-1].Weight != 1 {
+					t.Errorf("%s: the last solution has weight %g\n%s", prefix, anytimeSolutions[len(anytimeSolutions)-1].Weight, gridString(g))
+				}
+
+				araBudget = 0
+				path = ARAStar(start, goal)
+				if problem := pathProblem(path, start, goal); problem != "" {
+					t.Errorf("%s without time: %s\n%s", prefix, problem, gridString(g))
+				}
+				if (len(path) > 0) != (len(expected) > 0) {
+					t.Errorf("%s without time has %d node(s)\n%s", prefix, len(path), gridString(g))
+				}
+			}
+		}
+	}
+}
+
+/*
+ * A map where the searches with the larger weights find longer paths.
+ * The weights go down by araWeightStep from araInitialWeight, or from a
+ * larger --weight, and the paths get shorter until the shortest one with
+ * weight 1.
+ */
+func TestARAStarSolutions(t *testing.T) {
+	keepGlobals(t)
+	oldBudget := araBudget
+	t.Cleanup(func() {
+		araBudget = oldBudget
+	})
+	araBudget = time.Minute
+	g := gridFromRows(
+		"..@.....",
+		"@..@..@.",
+		".@@@@@..",
+		"@.....@@",
+		"..@@..@.",
+	)
+	nodeModel, connectivity = GridCorners, EightConnected
+	setGrid(g)
+	start, goal := NewNode(6, 1), NewNode(2, 3)
+	for _, weight := range([]float64{1, 4}) {
+		heuristicWeight = weight
+		ARAStar(start, goal)
+		expected := []AnytimeSolution{{nil, 3, 4 + 3*SQRT2, 0}, {nil, 2.5, 4 + 3*SQRT2, 0}, {nil, 2, 4 + 3*SQRT2, 0},
+		                               {nil, 1.5, 6 + SQRT2, 0}, {nil, 1, 6, 0}}
+		if weight == 4 {
+			expected = append([]AnytimeSolution{{nil, 4, 4 + 3*SQRT2, 0}, {nil, 3.5, 4 + 3*SQRT2, 0}}, expected...)
+		}
+		if len(anytimeSolutions) != len(expected) {
+			t.Fatalf("--weight %g: %d solution(s), expected %d", weight, len(anytimeSolutions), len(expected))
+		}
+		for k, s := range(anytimeSolutions) {
+			if s.Weight != expected[k].Weight || math.Abs(s.Length - expected[k].Length) > 1e-9 {
+				t.Errorf("--weight %g: solution %d has weight %g and length %f, expected %g and %f",
+					weight, k, s.Weight, s.Length, expected[k].Weight, expected[k].Length)
+			}
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"container/heap"
+	"math"
+)
+
+// Nodes expanded from the start and from the goal by the last call of a
+// bidirectional algorithm
+var expansionsPerDirection []int
+
+// The state of one direction of a bidirectional search
+type searchDirection struct {
+	target     Node // The start of the other direction
+	heuristic  func(Node, Node) float64
+	open       map[Node]bool
+	byF        openQueue // The open nodes ordered like openNodeWithLowestF
+	byG        nodeQueue // The open nodes ordered by g score
+	g          map[Node]float64
+	parent     map[Node]Node
+	timestamp  map[Node]int
+	expansions int
+}
+
+/*
+ * Open nodes in a binary heap, lowest f score first. A node is pushed
+ * again whenever its g score improves, and the entries whose g score is
+ * no longer that of the node, or whose node was closed, are skipped.
+ */
+type openEntry struct {
+	node      Node
+	f, g      float64
+	timestamp int
+}
+
+type openQueue []openEntry
+
+func (q openQueue) Len() int            { return len(q) }
+func (q openQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *openQueue) Push(x interface{}) { *q = append(*q, x.(openEntry)) }
+func (q *openQueue) Pop() interface{} {
+	old   := *q
+	entry := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return entry
+}
+func (q openQueue) Less(i, j int) bool {
+	a, b := q[i], q[j]
+	if a.f != b.f {
+		return a.f < b.f
+	}
+	return tieBreaksBefore(a.node, a.g, a.f - a.g, a.timestamp, b.node, b.g, b.f - b.g, b.timestamp)
+}
+
+func newSearchDirection(from, target Node, h func(Node, Node) float64) *searchDirection {
+	d := &searchDirection{
+		target:    target,
+		heuristic: h,
+		open:      map[Node]bool{},
+		g:         map[Node]float64{},
+		parent:    map[Node]Node{},
+		timestamp: map[Node]int{},
+	}
+	d.push(from, 0)
+	return d
+}
+
+func (d *searchDirection) size() int {
+	return len(d.open) + len(d.byF) + len(d.byG) + len(d.g) + len(d.parent) + len(d.timestamp)
+}
+
+// Opens a node with a new g score
+func (d *searchDirection) push(n Node, gScore float64) {
+	d.g[n]    = gScore
+	d.open[n] = true // Value doesn't matter
+	heap.Push(&d.byF, openEntry{n, gScore + d.heuristic(n, d.target), gScore, d.timestamp[n]})
+	heap.Push(&d.byG, queuedNode{n, gScore, gScore})
+}
+
+// Whether an entry is still that of an open node
+func (d *searchDirection) current(n Node, gScore float64) bool {
+	return d.open[n] && d.g[n] == gScore
+}
+
+// Missing g scores are infinite
+func (d *searchDirection) gScore(n Node) float64 {
+	if score, found := d.g[n]; found {
+		return score
+	}
+	return math.Inf(1)
+}
+
+// The open entry with the lowest f score, see openQueue
+func (d *searchDirection) lowestF() openEntry {
+	for !d.current(d.byF[0].node, d.byF[0].g) {
+		heap.Pop(&d.byF)
+	}
+	return d.byF[0]
+}
+
+func (d *searchDirection) lowestG() float64 {
+	for !d.current(d.byG[0].node, d.byG[0].g) {
+		heap.Pop(&d.byG)
+	}
+	return d.byG[0].g
+}
+
+func BidirectionalDijkstra(start, goal Node) []Node {
+	return bidirectionalSearch(start, goal, func(Node, Node) float64 {
+		return 0
+	})
+}
+
+func BidirectionalAStar(start, goal Node) []Node {
+	return bidirectionalSearch(start, goal, searchHeuristic(gridHeuristic))
+}
+
+/*
+ * Searches from the start and the goal at the same time, always in the
+ * direction with fewer open nodes. Moves are symmetric, so the backward
+ * search can use getTraversableNodes too. mu is the length of the best
+ * path found through a node reached from both sides. The heuristic must
+ * be consistent, so that closed nodes have their final g scores. Then no
+ * path left to find can be shorter than the lowest f score of either
+ * direction, or the lowest g scores of both directions added together,
+ * and the search stops when mu is not longer than the largest of these
+ * bounds (the MM criterion of Holte et al. 2016). Stopping when the two
+ * searches first meet would not give the shortest path.
+ */
+func bidirectionalSearch(start, goal Node, h func(Node, Node) float64) []Node {
+	expansionsPerDirection = []int{0, 0}
+	if !sameComponent(start, goal) {
+		return []Node{}
+	}
+	forward  := newSearchDirection(start, goal, h)
+	backward := newSearchDirection(goal, start, h)
+	mu       := math.Inf(1)
+	meeting  := start
+	if start == goal {
+		mu = 0
+	}
+	timestampCounter = 1 // Only the start of each direction has timestamp 0
+
+	for len(forward.open) > 0 && len(backward.open) > 0 {
+		noteStoredNodes(forward.size() + backward.size())
+		lowerBound := math.Max(forward.lowestG() + backward.lowestG(),
+		              math.Max(forward.lowestF().f, backward.lowestF().f))
+		if mu <= lowerBound {
+			break
+		}
+
+		d, other := forward, backward
+		if len(backward.open) < len(forward.open) {
+			d, other = backward, forward
+		}
+		node := heap.Pop(&d.byF).(openEntry).node
+		delete(d.open, node)
+		d.expansions++
+		for _, neighbour := range(getTraversableNodes(node)) {
+			tentativeG := d.g[node] + costToNeighbour(node, neighbour)
+			if tentativeG >= d.gScore(neighbour) {
+				continue
+			}
+			d.parent[neighbour]    = node
+			d.timestamp[neighbour] = timestampCounter
+			timestampCounter++
+			d.push(neighbour, tentativeG)
+			if length := tentativeG + other.gScore(neighbour); length < mu {
+				mu      = length
+				meeting = neighbour
+			}
+		}
+	}
+	expansionsPerDirection = []int{forward.expansions, backward.expansions}
+	expansions = forward.expansions + backward.expansions
+	if math.IsInf(mu, 1) {
+		return []Node{}
+	}
+
+	// Follow the parents from the meeting node to both ends
+	path := []Node{meeting}
+	for node := meeting; node != start; {
+		node = forward.parent[node]
+		path = append([]Node{node}, path...)
+	}
+	for node := meeting; node != goal; {
+		node = backward.parent[node]
+		path = append(path, node)
+	}
+	return path
+}
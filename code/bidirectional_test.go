@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+/*
+ * Compares the bidirectional searches with Dijkstra between random nodes
+ * on random maps of up to 24x24 cells, in every node model and
+ * connectivity: the paths must be valid and as short. The searches stop
+ * when the best meeting point cannot improve, which the lengths check.
+ */
+func TestBidirectionalSearch(t *testing.T) {
+	keepGlobals(t)
+	const epsilon = 1e-6
+	searches := map[string]func(Node, Node) []Node{"bidijkstra": BidirectionalDijkstra, "biastar": BidirectionalAStar}
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		g := randomGrid(random, 24, 24, 0.4)
+		for _, model := range(allNodeModels) {
+			for _, c := range(allConnectivities) {
+				nodeModel, connectivity = model, c
+				setGrid(g)
+				for j := 0; j < 10; j++ {
+					start    := randomNode(random)
+					goal     := randomNode(random)
+					expected := Dijkstra(start, goal)
+					for _, name := range([]string{"bidijkstra", "biastar"}) {
+						prefix := fmt.Sprintf("map %d, %s model, %s-connectivity: %s path (%d,%d) -> (%d,%d)",
+							i, nodeModelName(model), connectivityName(c), name, start.X, start.Y, goal.X, goal.Y)
+						path := searches[name](start, goal)
+						if problem := pathProblem(path, start, goal); problem != "" {
+							t.Errorf("%s: %s\n%s", prefix, problem, gridString(g))
+						}
+						if (len(path) > 0) != (len(expected) > 0) || math.Abs(PathLength(path) - PathLength(expected)) > epsilon {
+							t.Errorf("%s has length %f, expected %f\n%s", prefix, PathLength(path), PathLength(expected), gridString(g))
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+/*
+ * The start at the end of a corridor and the goal in a room: the search
+ * from the corridor has fewer open nodes, so it does most of the
+ * expansions, whichever end it starts from, and the room is hardly
+ * searched at all.
+ */
+func TestBidirectionalSearchDirections(t *testing.T) {
+	keepGlobals(t)
+	const epsilon = 1e-6
+	g := gridFromRows(
+		"@@@@@@@@@@",
+		"@........@",
+		"@@@@@@@..@",
+		"@........@",
+		"@........@",
+		"@........@",
+		"@@@@@@@@@@",
+	)
+	searches := map[string]func(Node, Node) []Node{"bidijkstra": BidirectionalDijkstra, "biastar": BidirectionalAStar}
+	for _, model := range(allNodeModels) {
+		nodeModel, connectivity = model, EightConnectedNoCornerCutting
+		setGrid(g)
+		corridor, room := NewNode(1, 1), NewNode(5, 4)
+		for name, search := range(searches) {
+			for _, ends := range([][2]Node{{corridor, room}, {room, corridor}}) {
+				path     := search(ends[0], ends[1])
+				expected := Dijkstra(ends[0], ends[1])
+				prefix   := fmt.Sprintf("%s model: %s path (%d,%d) -> (%d,%d)",
+					nodeModelName(model), name, ends[0].X, ends[0].Y, ends[1].X, ends[1].Y)
+				if math.Abs(PathLength(path) - PathLength(expected)) > epsilon {
+					t.Errorf("%s has length %f, expected %f", prefix, PathLength(path), PathLength(expected))
+				}
+				fromCorridor, fromRoom := expansionsPerDirection[0], expansionsPerDirection[1]
+				if ends[0] == room {
+					fromCorridor, fromRoom = fromRoom, fromCorridor
+				}
+				if fromRoom > 4 || fromCorridor <= fromRoom {
+					t.Errorf("%s expanded %d node(s) from the corridor and %d from the room", prefix, fromCorridor, fromRoom)
+				}
+			}
+		}
+	}
+	start := NewNode(3, 3)
+	if path := BidirectionalAStar(start, start); len(path) != 1 || expansions != 0 {
+		t.Errorf("biastar path from (3,3) to itself has %d node(s) and %d expansion(s)", len(path), expansions)
+	}
+}
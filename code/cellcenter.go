@@ -0,0 +1,67 @@
+package main
+
+type NodeModel int
+const (
+	GridCorners NodeModel = iota // Nodes are the corners of cells
+	CellCenters                  // Nodes are the centers of open cells
+)
+
+var nodeModel = GridCorners
+
+// The largest node coordinates of the current node model
+func maxNodeCoordinates() (int, int) {
+	if nodeModel == CellCenters {
+		return len(grid[0]) - 1, len(grid) - 1
+	}
+	return len(grid[0]), len(grid)
+}
+
+/*
+ * Neighbours in the cell-center model. Node (x,y) is the center of cell
+ * (x,y) and moves go between open cells, like in the movingai benchmarks.
+ * The side cells of a diagonal move are the two cells that share an edge
+ * with both the start and the destination cell.
+ */
+func getTraversableCells(node Node) []Node {
+	neighbours := []Node{}
+	x := node.X
+	y := node.Y
+	if !isOpen(x, y) {
+		return neighbours
+	}
+	nOpen := isOpen(x,   y-1)
+	eOpen := isOpen(x+1, y)
+	sOpen := isOpen(x,   y+1)
+	wOpen := isOpen(x-1, y)
+
+	if nOpen {
+		neighbours = append(neighbours, NewNode(x, y-1))
+	}
+	if eOpen {
+		neighbours = append(neighbours, NewNode(x+1, y))
+	}
+	if sOpen {
+		neighbours = append(neighbours, NewNode(x, y+1))
+	}
+	if wOpen {
+		neighbours = append(neighbours, NewNode(x-1, y))
+	}
+
+	if connectivity == FourConnected {
+		return neighbours
+	}
+
+	if isOpen(x-1, y-1) && diagonalAllowed(nOpen, wOpen) {
+		neighbours = append(neighbours, NewNode(x-1, y-1))
+	}
+	if isOpen(x+1, y-1) && diagonalAllowed(nOpen, eOpen) {
+		neighbours = append(neighbours, NewNode(x+1, y-1))
+	}
+	if isOpen(x+1, y+1) && diagonalAllowed(sOpen, eOpen) {
+		neighbours = append(neighbours, NewNode(x+1, y+1))
+	}
+	if isOpen(x-1, y+1) && diagonalAllowed(sOpen, wOpen) {
+		neighbours = append(neighbours, NewNode(x-1, y+1))
+	}
+	return neighbours
+}
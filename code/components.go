@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+// Connected components of the node graph given by getTraversableNodes.
+// components[y][x] is the label of node (x,y), or -1 for nodes without
+// traversable neighbours. A nil slice means that the labels are unknown.
+var components [][]int
+var componentSizes []int // Number of nodes in each component, indexed by label
+
+/*
+ * Replaces the current grid and labels its connected components, so
+ * every grid change must go through this function or SetCellBlocked.
+ */
+func setGrid(g [][]bool) {
+	if cellCosts != nil && (len(cellCosts) != len(g) || len(cellCosts[0]) != len(g[0])) {
+		fmt.Printf("The costs are for a %dx%d map, but the map is %dx%d\n", len(cellCosts[0]), len(cellCosts), len(g[0]), len(g))
+		os.Exit(1)
+	}
+	grid = g
+	hpaGraph      = nil
+	subgoalGraph  = nil
+	landmarkData  = nil
+	trueClearance = nil
+	labelComponents()
+}
+
+/*
+ * Blocks or opens one cell. Labelling the components again would take as
+ * long as a search, so they become unknown until the next setGrid. The
+ * HPA* and subgoal graphs, the landmarks and the true clearance are made
+ * again when they are needed.
+ */
+func SetCellBlocked(x, y int, blocked bool) {
+	grid[y][x]     = blocked
+	components     = nil
+	componentSizes = nil
+	hpaGraph       = nil
+	subgoalGraph   = nil
+	landmarkData   = nil
+	trueClearance  = nil
+}
+
+/*
+ * Labels the connected components with breadth-first searches over
+ * getTraversableNodes. Nodes are connected when a path exists between
+ * them, so every pathfinding algorithm fails exactly when the start
+ * and goal have different labels.
+ */
+func labelComponents() {
+	h := len(grid)
+	w := len(grid[0])
+	components = make([][]int, h+1)
+	for y := 0; y <= h; y++ {
+		components[y] = make([]int, w+1)
+		for x := 0; x <= w; x++ {
+			components[y][x] = -1
+		}
+	}
+	componentSizes = []int{}
+
+	for y := 0; y <= h; y++ {
+		for x := 0; x <= w; x++ {
+			if components[y][x] != -1 {
+				continue
+			}
+			node := NewNode(x, y)
+			if len(getTraversableNodes(node)) == 0 {
+				continue // Isolated nodes have no component
+			}
+			label := len(componentSizes)
+			size  := 0
+			components[y][x] = label
+			queue := []Node{node}
+			for len(queue) > 0 {
+				n := queue[0]
+				queue = queue[1:]
+				size++
+				for _, neighbour := range(getTraversableNodes(n)) {
+					if components[neighbour.Y][neighbour.X] == -1 {
+						components[neighbour.Y][neighbour.X] = label
+						queue = append(queue, neighbour)
+					}
+				}
+			}
+			componentSizes = append(componentSizes, size)
+		}
+	}
+}
+
+/*
+ * Returns the component label of a node, or -1 if the node is outside
+ * the map or has no traversable neighbours.
+ */
+func componentOf(n Node) int {
+	if n.Y < 0 || n.Y >= len(components) || n.X < 0 || n.X >= len(components[0]) {
+		return -1
+	}
+	return components[n.Y][n.X]
+}
+
+/*
+ * Returns false if no path exists between the nodes. This lets the
+ * algorithms give up before searching the whole map. Returns true if
+ * the components are not known.
+ */
+func sameComponent(n1, n2 Node) bool {
+	if components == nil || n1 == n2 {
+		return true
+	}
+	c := componentOf(n1)
+	return c != -1 && c == componentOf(n2)
+}
+
+/*
+ * Returns the number of open cells in each component, indexed by label.
+ * An open cell belongs to the component of its top-left node, which is
+ * the cell itself in the cell-center model.
+ */
+func componentCellCounts() []int {
+	counts := make([]int, len(componentSizes))
+	for y := 0; y < len(grid); y++ {
+		for x := 0; x < len(grid[0]); x++ {
+			if !grid[y][x] && components[y][x] != -1 {
+				counts[components[y][x]]++
+			}
+		}
+	}
+	return counts
+}
+
+/*
+ * Returns the component labels sorted from the largest to the smallest
+ * component.
+ */
+func componentsBySize() []int {
+	labels := make([]int, len(componentSizes))
+	for i := range(labels) {
+		labels[i] = i
+	}
+	sort.SliceStable(labels, func(i, j int) bool {
+		return componentSizes[labels[i]] > componentSizes[labels[j]]
+	})
+	return labels
+}
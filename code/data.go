@@ -24,6 +24,48 @@ func StraightLineDist(n1, n2 Node) float64 {
 	return math.Sqrt(dx*dx + dy*dy)
 }
 
+// Sum of the straight line distances between consecutive path nodes
+func PathLength(path []Node) float64 {
+	length := 0.0
+	for i := 0; i < len(path)-1; i++ {
+		length += StraightLineDist(path[i], path[i+1])
+	}
+	return length
+}
+
+// A point in map coordinates, where cell (x,y) is the unit square with
+// its top-left corner at (x,y)
+type Point struct {
+	X float64
+	Y float64
+}
+
+// The points of the nodes, which are in the middle of their cells in the
+// cell-center model
+func NodesToPoints(path []Node) []Point {
+	offset := 0.0
+	if nodeModel == CellCenters {
+		offset = 0.5
+	}
+	points := []Point{}
+	for _, n := range(path) {
+		points = append(points, Point{float64(n.X) + offset, float64(n.Y) + offset})
+	}
+	return points
+}
+
+func PointDist(p1, p2 Point) float64 {
+	return math.Hypot(p1.X - p2.X, p1.Y - p2.Y)
+}
+
+func PointPathLength(path []Point) float64 {
+	length := 0.0
+	for i := 0; i < len(path)-1; i++ {
+		length += PointDist(path[i], path[i+1])
+	}
+	return length
+}
+
 type Scenario struct {
 	Path     string // Filepath to its belonging scenarios file
 	Bucket   int
@@ -34,3 +76,17 @@ type Scenario struct {
 	Goal     Node
 	OptimalLength  float64
 }
+
+type MapChangeKind int
+const (
+	BlockCell MapChangeKind = iota
+	OpenCell
+	MoveStart // Moves the start to the node (X,Y)
+	Replan
+)
+
+// One line of a map changes file
+type MapChange struct {
+	Kind MapChangeKind
+	X, Y int
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"container/heap"
+	"math"
+)
+
+/*
+ * D* Lite (Koenig and Likhachev 2002) searches backwards from the goal
+ * and keeps its g and rhs scores between searches. When cells change or
+ * the start moves, only the nodes whose scores are affected are searched
+ * again. rhs is the one-step lookahead of g: the lowest cost through a
+ * neighbour. Nodes where g and rhs differ are inconsistent and are kept
+ * in a priority queue.
+ */
+type DStarLite struct {
+	start, goal Node
+	km          float64 // Added to the keys every time the start moves
+	g, rhs      map[Node]float64
+	queue       nodeQueue
+	keys        map[Node]dstarKey // The current keys of the nodes in the queue
+	heuristic   func(Node, Node) float64
+	Expansions  int               // Nodes expanded by the last call of Replan
+}
+
+// Queued as the priority and the g score of a queuedNode. Queued keys
+// that differ from the keys map are outdated and skipped.
+type dstarKey [2]float64
+
+/*
+ * The first parts are sums of costs, heuristics and km, so parts that are
+ * equal in exact arithmetic can differ by rounding. They are compared
+ * with a tolerance, or a node whose key ties with the start would look
+ * larger and the search would stop before it.
+ */
+func (k dstarKey) less(other dstarKey) bool {
+	const epsilon = 1e-9
+	if math.Abs(k[0] - other[0]) > epsilon {
+		return k[0] < other[0]
+	}
+	return k[1] < other[1]
+}
+
+func NewDStarLite(start, goal Node) *DStarLite {
+	d := &DStarLite{
+		start:     start,
+		goal:      goal,
+		g:         map[Node]float64{},
+		rhs:       map[Node]float64{goal: 0},
+		keys:      map[Node]dstarKey{},
+		heuristic: dstarHeuristic(),
+	}
+	d.insert(goal)
+	return d
+}
+
+/*
+ * The heuristic of D* Lite. The landmark distances only hold on the map
+ * they were measured on and are dropped when a cell changes, while the
+ * planner keeps its heuristic between searches, so the default heuristic
+ * is used instead of them.
+ */
+func dstarHeuristic() func(Node, Node) float64 {
+	if selectedHeuristic == "landmark" {
+		return gridHeuristic
+	}
+	return searchHeuristic(gridHeuristic)
+}
+
+/*
+ * Plans a path with a new D* Lite planner, for comparing it with the
+ * other algorithms on a static map.
+ */
+func DStarLiteSearch(start, goal Node) []Node {
+	if !sameComponent(start, goal) {
+		return []Node{}
+	}
+	return NewDStarLite(start, goal).Replan()
+}
+
+// Missing scores are infinite
+func (d *DStarLite) score(scores map[Node]float64, n Node) float64 {
+	if s, found := scores[n]; found {
+		return s
+	}
+	return math.Inf(1)
+}
+
+func (d *DStarLite) calculateKey(n Node) dstarKey {
+	m := math.Min(d.score(d.g, n), d.score(d.rhs, n))
+	return dstarKey{m + d.heuristic(d.start, n) + d.km, m}
+}
+
+func (d *DStarLite) insert(n Node) {
+	key := d.calculateKey(n)
+	d.keys[n] = key
+	heap.Push(&d.queue, queuedNode{n, key[0], key[1]})
+}
+
+// Returns the node with the lowest key, or false if the queue is empty
+func (d *DStarLite) top() (Node, dstarKey, bool) {
+	for len(d.queue) > 0 {
+		entry := d.queue[0]
+		if key, found := d.keys[entry.node]; found && key == (dstarKey{entry.priority, entry.g}) {
+			return entry.node, key, true
+		}
+		heap.Pop(&d.queue)
+	}
+	return Node{}, dstarKey{}, false
+}
+
+// Recomputes rhs of a node and puts it in the queue if it is inconsistent
+func (d *DStarLite) updateNode(n Node) {
+	if n != d.goal {
+		rhs := math.Inf(1)
+		for _, neighbour := range(getTraversableNodes(n)) {
+			rhs = math.Min(rhs, costToNeighbour(n, neighbour) + d.score(d.g, neighbour))
+		}
+		d.rhs[n] = rhs
+	}
+	delete(d.keys, n)
+	if d.score(d.g, n) != d.score(d.rhs, n) {
+		d.insert(n)
+	}
+}
+
+func (d *DStarLite) computeShortestPath() {
+	for {
+		n, key, found := d.top()
+		if !found {
+			return
+		}
+		if !key.less(d.calculateKey(d.start)) && d.score(d.rhs, d.start) == d.score(d.g, d.start) {
+			return
+		}
+		d.Expansions++
+		noteStoredNodes(len(d.g) + len(d.rhs) + len(d.queue) + len(d.keys))
+		if newKey := d.calculateKey(n); key.less(newKey) {
+			d.keys[n] = newKey
+			heap.Push(&d.queue, queuedNode{n, newKey[0], newKey[1]})
+		} else if d.score(d.g, n) > d.score(d.rhs, n) {
+			d.g[n] = d.rhs[n]
+			delete(d.keys, n)
+			for _, neighbour := range(getTraversableNodes(n)) {
+				d.updateNode(neighbour)
+			}
+		} else {
+			d.g[n] = math.Inf(1)
+			for _, neighbour := range(getTraversableNodes(n)) {
+				d.updateNode(neighbour)
+			}
+			d.updateNode(n)
+		}
+	}
+}
+
+/*
+ * Moves the start, for example when the agent has followed the path.
+ * The heuristic of every queued key now measures from a different
+ * start, which km makes up for instead of recomputing the keys.
+ */
+func (d *DStarLite) MoveStart(start Node) {
+	d.km   += d.heuristic(d.start, start)
+	d.start = start
+}
+
+/*
+ * Tells the planner that cell (x,y) was blocked or opened with
+ * SetCellBlocked. Every move that depends on the cell starts or ends
+ * within two nodes of it, in both node models and all connectivities,
+ * so those nodes are updated. A larger agent covers the cell from the
+ * cells up and to the left of it where it may fit, which moves the
+ * nodes that depend on the cell further up and to the left.
+ */
+func (d *DStarLite) UpdateCell(x, y int) {
+	reach := 0
+	if agentSize > 0 {
+		reach = agentClearanceNeeded(nodeModel) - 1
+	}
+	maxX, maxY := maxNodeCoordinates()
+	for ny := int(math.Max(0, float64(y-2-reach))); ny <= int(math.Min(float64(maxY), float64(y+3))); ny++ {
+		for nx := int(math.Max(0, float64(x-2-reach))); nx <= int(math.Min(float64(maxX), float64(x+3))); nx++ {
+			d.updateNode(NewNode(nx, ny))
+		}
+	}
+}
+
+/*
+ * Brings the scores up to date and returns the shortest path from the
+ * start to the goal, or an empty path if there is none.
+ */
+func (d *DStarLite) Replan() []Node {
+	d.Expansions = 0
+	d.computeShortestPath()
+	if math.IsInf(d.score(d.g, d.start), 1) {
+		return []Node{}
+	}
+
+	// Follow the lowest cost through each neighbour to the goal
+	path := []Node{d.start}
+	for node := d.start; node != d.goal; {
+		best     := node
+		bestCost := math.Inf(1)
+		for _, neighbour := range(getTraversableNodes(node)) {
+			cost := costToNeighbour(node, neighbour) + d.score(d.g, neighbour)
+			if cost < bestCost {
+				best, bestCost = neighbour, cost
+			}
+		}
+		if best == node {
+			panic("Assertion failed: D* Lite path is broken")
+		}
+		node = best
+		path = append(path, node)
+	}
+	return path
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+/*
+ * Replans with D* Lite while random cells are blocked and opened on random
+ * maps of up to 16x16 cells, in every node model and connectivity, with
+ * the landmark heuristic selected, and compares the lengths with Dijkstra
+ * from scratch. The landmarks are dropped with every change, so D* Lite
+ * must not use them.
+ */
+func TestDStarLiteReplanning(t *testing.T) {
+	keepGlobals(t)
+	selectedHeuristic = "landmark"
+	const epsilon = 1e-6
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 50; i++ {
+		g := randomGrid(random, 16, 16, 0.4)
+		for _, model := range(allNodeModels) {
+			for _, c := range(allConnectivities) {
+				nodeModel, connectivity = model, c
+				// The planner changes the cells
+				cells := make([][]bool, len(g))
+				for y := range(g) {
+					cells[y] = append([]bool{}, g[y]...)
+				}
+				setGrid(cells)
+				start   := randomNode(random)
+				goal    := randomNode(random)
+				planner := NewDStarLite(start, goal)
+				for replan := 0; replan < 5; replan++ {
+					if replan > 0 {
+						for k := 0; k < 3; k++ {
+							x, y := random.Intn(len(cells[0])), random.Intn(len(cells))
+							SetCellBlocked(x, y, !cells[y][x])
+							planner.UpdateCell(x, y)
+						}
+					}
+					got := planner.Replan()
+					expected := Dijkstra(start, goal)
+					if problem := pathProblem(got, start, goal); problem != "" {
+						t.Errorf("map %d, %s model, %s-connectivity, replan %d: D* Lite path (%d,%d) -> (%d,%d): %s\n%s",
+							i, nodeModelName(model), connectivityName(c), replan, start.X, start.Y, goal.X, goal.Y, problem, gridString(cells))
+					}
+					if (len(got) > 0) != (len(expected) > 0) || math.Abs(PathLength(got) - PathLength(expected)) > epsilon {
+						t.Errorf("map %d, %s model, %s-connectivity, replan %d: D* Lite path (%d,%d) -> (%d,%d) has length %f, expected %f\n%s",
+							i, nodeModelName(model), connectivityName(c), replan, start.X, start.Y, goal.X, goal.Y, PathLength(got), PathLength(expected), gridString(cells))
+					}
+				}
+			}
+		}
+	}
+}
+
+/*
+ * A wall with a gap and a way around its end, between cell centers with
+ * 4-connectivity. Closing the gap makes the path go around, closing the
+ * way around too leaves none, and opening the gap again brings the first
+ * path back. A replan without changes expands nothing.
+ */
+func TestDStarLiteGap(t *testing.T) {
+	keepGlobals(t)
+	cells := gridFromRows(
+		"..........",
+		"@@@@.@@@@.",
+		"..........",
+	)
+	nodeModel, connectivity = CellCenters, FourConnected
+	setGrid(cells)
+	start, goal := NewNode(0, 0), NewNode(0, 2)
+	planner := NewDStarLite(start, goal)
+	steps := []struct {
+		x, y    int
+		blocked bool
+		length  float64
+	}{
+		{-1, -1, false, 10},
+		{-1, -1, false, 10},
+		{4, 1, true, 20},
+		{9, 1, true, 0},
+		{4, 1, false, 10},
+	}
+	for i, step := range(steps) {
+		if step.x >= 0 {
+			SetCellBlocked(step.x, step.y, step.blocked)
+			planner.UpdateCell(step.x, step.y)
+		}
+		path := planner.Replan()
+		if problem := pathProblem(path, start, goal); problem != "" {
+			t.Errorf("step %d: %s\n%s", i, problem, gridString(cells))
+		}
+		if PathLength(path) != step.length {
+			t.Errorf("step %d: the path has length %f, expected %f\n%s", i, PathLength(path), step.length, gridString(cells))
+		}
+		if i == 1 && planner.Expansions != 0 {
+			t.Errorf("step %d: %d expansion(s) without a change", i, planner.Expansions)
+		}
+	}
+}
+
+/*
+ * Moves the start of D* Lite up to 8 nodes along its last path between
+ * changes of cells, like an agent that follows its plan, on random maps
+ * of up to 24x24 cells in every node model and connectivity. Half of the
+ * changed cells are at nodes of the path. The keys of the queued nodes
+ * were computed from earlier starts, which km makes up for, and every
+ * replanned path must be as short as Dijkstra's from the new start.
+ */
+func TestDStarLiteMoveStart(t *testing.T) {
+	keepGlobals(t)
+	const epsilon = 1e-6
+	random := rand.New(rand.NewSource(2))
+	for i := 0; i < 50; i++ {
+		g := randomGrid(random, 24, 24, 0.3)
+		for _, model := range(allNodeModels) {
+			for _, c := range(allConnectivities) {
+				nodeModel, connectivity = model, c
+				cells := make([][]bool, len(g))
+				for y := range(g) {
+					cells[y] = append([]bool{}, g[y]...)
+				}
+				setGrid(cells)
+				start   := randomNode(random)
+				goal    := randomNode(random)
+				planner := NewDStarLite(start, goal)
+				path    := planner.Replan()
+				for move := 0; move < 8 && len(path) > 1; move++ {
+					start = path[1 + random.Intn(int(math.Min(8, float64(len(path)-1))))]
+					planner.MoveStart(start)
+					for k := 0; k < 4; k++ {
+						x, y := random.Intn(len(cells[0])), random.Intn(len(cells))
+						if k % 2 == 0 {
+							// A cell at a node of the path, where it changes the plan
+							n := path[random.Intn(len(path))]
+							x, y = n.X % len(cells[0]), n.Y % len(cells)
+						}
+						SetCellBlocked(x, y, !cells[y][x])
+						planner.UpdateCell(x, y)
+					}
+					path = planner.Replan()
+					expected := Dijkstra(start, goal)
+					prefix := fmt.Sprintf("map %d, %s model, %s-connectivity, move %d: D* Lite path (%d,%d) -> (%d,%d)",
+						i, nodeModelName(model), connectivityName(c), move, start.X, start.Y, goal.X, goal.Y)
+					if problem := pathProblem(path, start, goal); problem != "" {
+						t.Errorf("%s: %s\n%s", prefix, problem, gridString(cells))
+					}
+					if (len(path) > 0) != (len(expected) > 0) || math.Abs(PathLength(path) - PathLength(expected)) > epsilon {
+						t.Errorf("%s has length %f, expected %f\n%s", prefix, PathLength(path), PathLength(expected), gridString(cells))
+					}
+				}
+			}
+		}
+	}
+}
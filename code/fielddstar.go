@@ -0,0 +1,297 @@
+package main
+
+import (
+	"container/heap"
+	"math"
+)
+
+// Cost of moving one unit through each cell, indexed like the grid. A nil
+// slice means that every open cell costs 1. Only Field D* uses the costs.
+var cellCosts [][]float64
+
+// Blocked cells and cells outside the map cost infinitely much
+func cellCost(x, y int) float64 {
+	if !isOpen(x, y) {
+		return math.Inf(1)
+	}
+	if cellCosts == nil {
+		return 1
+	}
+	return cellCosts[y][x]
+}
+
+func lowestCellCost() float64 {
+	lowest := math.Inf(1)
+	for y := 0; y < len(grid); y++ {
+		for x := 0; x < len(grid[0]); x++ {
+			lowest = math.Min(lowest, cellCost(x, y))
+		}
+	}
+	return lowest
+}
+
+// The neighbours of a grid corner in counterclockwise order, so that
+// every consecutive pair spans one cell
+var fieldNeighbours = [8][2]int{{1,0}, {1,-1}, {0,-1}, {-1,-1}, {-1,0}, {-1,1}, {0,1}, {1,1}}
+
+/*
+ * Field D* (Ferguson and Stentz 2005). The g scores are computed for grid
+ * corners like in A*, but a path from a corner may leave through any point
+ * on the far edges of the cells around it, where the g score is linearly
+ * interpolated between the two corners of the edge. This gives any-angle
+ * paths that take the cell costs into account, crossing cell edges
+ * anywhere instead of only at corners. The scores are computed once
+ * backwards from the goal, because the map does not change between
+ * queries. Nodes are always grid corners, and in the cell-center model
+ * the path starts and ends in the middle of the start and goal cells.
+ */
+func FieldDStar(start, goal Node) []Point {
+	startPoint := NodesToPoints([]Node{start})[0]
+	goalPoint  := NodesToPoints([]Node{goal})[0]
+	if start == goal {
+		return []Point{startPoint}
+	}
+	fieldG := fieldDStarScores(startPoint, goalPoint)
+	if fieldG == nil {
+		return []Point{}
+	}
+	return fieldDStarPath(fieldG, startPoint, goalPoint)
+}
+
+// The corners of the cells that contain the point, or the point itself if
+// it is a corner
+func cornersAround(p Point) []Node {
+	if p.X == math.Floor(p.X) && p.Y == math.Floor(p.Y) {
+		return []Node{NewNode(int(p.X), int(p.Y))}
+	}
+	x := int(math.Floor(p.X))
+	y := int(math.Floor(p.Y))
+	return []Node{NewNode(x, y), NewNode(x+1, y), NewNode(x, y+1), NewNode(x+1, y+1)}
+}
+
+/*
+ * Searches from the goal towards the start, in the order of g plus the
+ * straight line distance to the start times the lowest cell cost. Returns
+ * the g scores, or nil if the corners around the start cannot be reached.
+ */
+func fieldDStarScores(startPoint, goalPoint Point) map[Node]float64 {
+	fieldG := map[Node]float64{}
+	rhs    := map[Node]float64{}
+	queue  := nodeQueue{}
+	lowest := lowestCellCost()
+	h := func(n Node) float64 {
+		return lowest * PointDist(Point{float64(n.X), float64(n.Y)}, startPoint)
+	}
+	push := func(n Node, cost float64) {
+		rhs[n] = cost
+		heap.Push(&queue, queuedNode{n, cost + h(n), cost})
+	}
+
+	// A goal in the middle of a cell is reached straight from its corners
+	goalCorners := cornersAround(goalPoint)
+	if len(goalCorners) == 1 {
+		push(goalCorners[0], 0)
+	} else if c := cellCost(int(goalPoint.X), int(goalPoint.Y)); !math.IsInf(c, 1) {
+		for _, n := range(goalCorners) {
+			push(n, c * PointDist(Point{float64(n.X), float64(n.Y)}, goalPoint))
+		}
+	}
+
+	remaining := map[Node]bool{}
+	for _, n := range(cornersAround(startPoint)) {
+		remaining[n] = true
+	}
+	for len(queue) > 0 && len(remaining) > 0 {
+		entry := heap.Pop(&queue).(queuedNode)
+		n := entry.node
+		if _, found := fieldG[n]; found || entry.g != rhs[n] {
+			continue // Outdated entry
+		}
+		fieldG[n] = rhs[n]
+		delete(remaining, n)
+		noteStoredNodes(len(fieldG) + len(rhs) + len(queue))
+		for _, d := range(fieldNeighbours) {
+			neighbour := NewNode(n.X + d[0], n.Y + d[1])
+			if neighbour.X < 0 || neighbour.Y < 0 || neighbour.X > len(grid[0]) || neighbour.Y > len(grid) {
+				continue
+			}
+			if _, found := fieldG[neighbour]; found {
+				continue
+			}
+			cost := fieldNodeCost(fieldG, neighbour)
+			if old, found := rhs[neighbour]; !found || cost < old {
+				push(neighbour, cost)
+			}
+		}
+	}
+	if len(remaining) == len(cornersAround(startPoint)) {
+		return nil
+	}
+	return fieldG
+}
+
+func fieldScore(fieldG map[Node]float64, n Node) float64 {
+	if g, found := fieldG[n]; found {
+		return g
+	}
+	return math.Inf(1)
+}
+
+// The lowest cost from a corner through the cells around it
+func fieldNodeCost(fieldG map[Node]float64, n Node) float64 {
+	lowest := math.Inf(1)
+	for i := range(fieldNeighbours) {
+		a := fieldNeighbours[i]
+		b := fieldNeighbours[(i+1) % 8]
+		// s1 shares an edge with n and s2 is diagonal to n
+		s1, s2 := a, b
+		if a[0] != 0 && a[1] != 0 {
+			s1, s2 = b, a
+		}
+		// c is the cell with corners n, s1 and s2, and b the cell on the
+		// other side of the edge from n to s1
+		c := cellCost(n.X + int(math.Min(0, float64(s2[0]))), n.Y + int(math.Min(0, float64(s2[1]))))
+		s3 := [2]int{2*s1[0] - s2[0], 2*s1[1] - s2[1]}
+		bCost := cellCost(n.X + int(math.Min(0, float64(s3[0]))), n.Y + int(math.Min(0, float64(s3[1]))))
+		g1 := fieldScore(fieldG, NewNode(n.X + s1[0], n.Y + s1[1]))
+		g2 := fieldScore(fieldG, NewNode(n.X + s2[0], n.Y + s2[1]))
+		lowest = math.Min(lowest, interpolatedCost(c, bCost, g1, g2))
+	}
+	return lowest
+}
+
+/*
+ * The cost from a corner s through cell c to the edge between its edge
+ * neighbour s1 and diagonal neighbour s2, or along the edge from s to s1,
+ * whose other side is cell b. From Ferguson and Stentz: the path either
+ * goes straight to a point on the edge from s1 to s2, or first runs along
+ * the edge from s towards s1 and then crosses c to s2.
+ */
+func interpolatedCost(c, b, g1, g2 float64) float64 {
+	if math.IsInf(g1, 1) && math.IsInf(g2, 1) {
+		return math.Inf(1)
+	}
+	if math.IsInf(c, 1) || g1 <= g2 {
+		return math.Min(c, b) + g1
+	}
+	f := g1 - g2
+	if f <= b {
+		if c <= f {
+			return c*SQRT2 + g2
+		}
+		y := math.Min(f / math.Sqrt(c*c - f*f), 1)
+		return c*math.Sqrt(1 + y*y) + f*(1 - y) + g2
+	}
+	if c <= b {
+		return c*SQRT2 + g2
+	}
+	x := 1 - math.Min(b / math.Sqrt(c*c - b*b), 1)
+	return c*math.Sqrt(1 + (1-x)*(1-x)) + b*x + g2
+}
+
+/*
+ * Follows the interpolated g scores from the start. From every point the
+ * path goes straight to the cheapest point on the edges of the cells
+ * around it, until the goal is reached.
+ */
+func fieldDStarPath(fieldG map[Node]float64, startPoint, goalPoint Point) []Point {
+	path := []Point{startPoint}
+	p    := startPoint
+	for steps := 0; p != goalPoint; steps++ {
+		// The interpolated descent can stall or go round in circles
+		if steps > 4 * (len(grid) + 1) * (len(grid[0]) + 1) {
+			return []Point{}
+		}
+		next, found := fieldNextPoint(fieldG, p, goalPoint)
+		if !found {
+			return []Point{}
+		}
+		p = next
+		path = append(path, p)
+	}
+	return path
+}
+
+func fieldNextPoint(fieldG map[Node]float64, p, goalPoint Point) (Point, bool) {
+	best     := p
+	bestCost := math.Inf(1)
+	consider := func(q Point, cost float64) {
+		if cost < bestCost && q != p {
+			best, bestCost = q, cost
+		}
+	}
+	gAt := func(n Node) float64 {
+		return fieldScore(fieldG, n)
+	}
+
+	// The cells that p is inside of or on the edge of
+	minX := int(math.Ceil(p.X)) - 1
+	minY := int(math.Ceil(p.Y)) - 1
+	for cy := minY; cy <= int(math.Floor(p.Y)); cy++ {
+		for cx := minX; cx <= int(math.Floor(p.X)); cx++ {
+			c := cellCost(cx, cy)
+			if math.IsInf(c, 1) {
+				continue
+			}
+			if goalPoint.X >= float64(cx) && goalPoint.X <= float64(cx+1) &&
+			   goalPoint.Y >= float64(cy) && goalPoint.Y <= float64(cy+1) {
+				consider(goalPoint, c * PointDist(p, goalPoint))
+			}
+			corners := []Node{NewNode(cx, cy), NewNode(cx+1, cy), NewNode(cx+1, cy+1), NewNode(cx, cy+1)}
+			for i := range(corners) {
+				a, b := corners[i], corners[(i+1) % 4]
+				pa := Point{float64(a.X), float64(a.Y)}
+				pb := Point{float64(b.X), float64(b.Y)}
+				ga, gb := gAt(a), gAt(b)
+				if onEdge(p, pa, pb) || math.IsInf(ga, 1) || math.IsInf(gb, 1) {
+					// Along the edge, or to a corner if the edge is not
+					// interpolated
+					consider(pa, c * PointDist(p, pa) + ga)
+					consider(pb, c * PointDist(p, pb) + gb)
+					continue
+				}
+				t := minimizeOnEdge(p, pa, pb, c, ga, gb)
+				q := Point{pa.X + t*(pb.X - pa.X), pa.Y + t*(pb.Y - pa.Y)}
+				consider(q, c * PointDist(p, q) + ga + t*(gb - ga))
+			}
+		}
+	}
+	return best, !math.IsInf(bestCost, 1)
+}
+
+// Whether p lies on the axis-aligned unit edge from a to b
+func onEdge(p, a, b Point) bool {
+	return (a.X == b.X && p.X == a.X && p.Y >= math.Min(a.Y, b.Y) && p.Y <= math.Max(a.Y, b.Y)) ||
+	       (a.Y == b.Y && p.Y == a.Y && p.X >= math.Min(a.X, b.X) && p.X <= math.Max(a.X, b.X))
+}
+
+/*
+ * Finds the t in [0,1] with the lowest cost of going from p to the point
+ * at t on the edge from a to b, plus the interpolated g score there. The
+ * cost is convex in t, so a ternary search finds it. Values close to the
+ * ends snap to the corners to avoid tiny steps.
+ */
+func minimizeOnEdge(p, a, b Point, c, ga, gb float64) float64 {
+	cost := func(t float64) float64 {
+		q := Point{a.X + t*(b.X - a.X), a.Y + t*(b.Y - a.Y)}
+		return c * PointDist(p, q) + ga + t*(gb - ga)
+	}
+	lo, hi := 0.0, 1.0
+	for i := 0; i < 100; i++ {
+		m1 := lo + (hi - lo)/3
+		m2 := hi - (hi - lo)/3
+		if cost(m1) < cost(m2) {
+			hi = m2
+		} else {
+			lo = m1
+		}
+	}
+	t := (lo + hi) / 2
+	if t < 1e-9 {
+		return 0
+	}
+	if t > 1 - 1e-9 {
+		return 1
+	}
+	return t
+}
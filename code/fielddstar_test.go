@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+/*
+ * Finds Field D* paths between random nodes on random maps of up to 16x16
+ * cells in both node models, with and without random cell costs. Every
+ * path must be valid and be found whenever the start and the goal are
+ * connected. The interpolated descent must not run off or go round in
+ * circles.
+ */
+func TestFieldDStar(t *testing.T) {
+	keepGlobals(t)
+	oldCosts := cellCosts
+	t.Cleanup(func() {
+		cellCosts = oldCosts
+	})
+	connectivity = EightConnected
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		g := randomGrid(random, 16, 16, 0.4)
+		cellCosts = nil
+		if i % 2 == 1 {
+			cellCosts = make([][]float64, len(g))
+			for y := range(g) {
+				cellCosts[y] = make([]float64, len(g[0]))
+				for x := range(cellCosts[y]) {
+					cellCosts[y][x] = 1 + 4 * random.Float64()
+				}
+			}
+		}
+		for _, model := range(allNodeModels) {
+			nodeModel = model
+			setGrid(g)
+			maxX, maxY := maxNodeCoordinates()
+			for j := 0; j < 10; j++ {
+				start := NewNode(random.Intn(maxX+1), random.Intn(maxY+1))
+				goal  := NewNode(random.Intn(maxX+1), random.Intn(maxY+1))
+				path  := FieldDStar(start, goal)
+				if err := ValidatePath(path, start, goal, FreeLines); err != nil {
+					t.Errorf("map %d, %s model: Field D* path (%d,%d) -> (%d,%d): %s\n%s",
+						i, nodeModelName(model), start.X, start.Y, goal.X, goal.Y, err.Error(), gridString(g))
+				}
+			}
+		}
+	}
+}
+
+/*
+ * On an open map the interpolated paths are any-angle: shorter than the
+ * octile distance and close to the straight line, which the small bends
+ * at the cell edges keep them from being exactly.
+ */
+func TestFieldDStarOpenMap(t *testing.T) {
+	keepGlobals(t)
+	nodeModel, connectivity = GridCorners, EightConnected
+	setGrid(gridFromRows(
+		"......",
+		"......",
+		"......",
+		"......",
+	))
+	start := NewNode(0, 0)
+	for _, goal := range([]Node{NewNode(3, 1), NewNode(5, 2), NewNode(6, 4), NewNode(1, 4)}) {
+		prefix   := fmt.Sprintf("Field D* path (0,0) -> (%d,%d)", goal.X, goal.Y)
+		length   := PointPathLength(FieldDStar(start, goal))
+		straight := math.Hypot(float64(goal.X), float64(goal.Y))
+		dx, dy   := math.Max(float64(goal.X), float64(goal.Y)), math.Min(float64(goal.X), float64(goal.Y))
+		octile   := dx - dy + SQRT2*dy
+		if length < straight - 1e-9 || length > 1.03 * straight || length >= octile {
+			t.Errorf("%s has length %f, the straight line %f and the octile distance %f", prefix, length, straight, octile)
+		}
+	}
+}
+
+/*
+ * A row of cells that cost 1 above two rows that cost 4. The path between
+ * the bottom corners of the map takes the cheap row, and like light it
+ * leaves the start at the angle where the sine is the ratio of the costs,
+ * so it is 1/sqrt(15) across for every cell up.
+ */
+func TestFieldDStarCellCosts(t *testing.T) {
+	keepGlobals(t)
+	oldCosts := cellCosts
+	t.Cleanup(func() {
+		cellCosts = oldCosts
+	})
+	nodeModel, connectivity = GridCorners, EightConnected
+	setGrid(gridFromRows(
+		"........",
+		"........",
+		"........",
+	))
+	start, goal := NewNode(0, 3), NewNode(8, 3)
+	cellCosts = nil
+	if length := PointPathLength(FieldDStar(start, goal)); length != 8 {
+		t.Errorf("Field D* path (0,3) -> (8,3) has length %f without costs, expected 8", length)
+	}
+	cellCosts = [][]float64{
+		{1, 1, 1, 1, 1, 1, 1, 1},
+		{4, 4, 4, 4, 4, 4, 4, 4},
+		{4, 4, 4, 4, 4, 4, 4, 4},
+	}
+	path := FieldDStar(start, goal)
+	if err := ValidatePath(path, start, goal, FreeLines); err != nil {
+		t.Fatalf("Field D* path (0,3) -> (8,3): %s", err.Error())
+	}
+	across := 1 / math.Sqrt(15)
+	for k, expected := range([]Point{{across, 2}, {2*across, 1}}) {
+		if PointDist(path[k+1], expected) > 1e-6 {
+			t.Errorf("point %d of the Field D* path (0,3) -> (8,3) is (%f,%f), expected (%f,%f)",
+				k+1, path[k+1].X, path[k+1].Y, expected.X, expected.Y)
+		}
+	}
+	for _, p := range(path[2:len(path)-2]) {
+		if p.Y != 1 {
+			t.Errorf("the Field D* path (0,3) -> (8,3) leaves the cheap row at (%f,%f)", p.X, p.Y)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+var allConnectivities = []Connectivity{EightConnected, EightConnectedNoCornerCutting, FourConnected}
+var allNodeModels     = []NodeModel{GridCorners, CellCenters}
+
+/*
+ * Saves the globals that the tests change and restores them when the test
+ * ends. The data derived from the grid is made again when it is needed.
+ */
+func keepGlobals(t *testing.T) {
+	oldGrid, oldConnectivity, oldModel, oldAgentSize := grid, connectivity, nodeModel, agentSize
+	oldHeuristic, oldWeight := selectedHeuristic, heuristicWeight
+	t.Cleanup(func() {
+		grid, connectivity, nodeModel, agentSize = oldGrid, oldConnectivity, oldModel, oldAgentSize
+		selectedHeuristic, heuristicWeight = oldHeuristic, oldWeight
+		components       = nil
+		componentSizes   = nil
+		hpaGraph         = nil
+		hpaFallbacks     = 0
+		subgoalGraph     = nil
+		subgoalFallbacks = 0
+		landmarkData     = nil
+		trueClearance    = nil
+		if grid != nil {
+			labelComponents()
+		}
+	})
+}
+
+/*
+ * A random map of 1 to maxW x 1 to maxH cells, often not square, whose
+ * cells are blocked with a random density of up to maxDensity.
+ */
+func randomGrid(random *rand.Rand, maxW, maxH int, maxDensity float64) [][]bool {
+	w := 1 + random.Intn(maxW)
+	h := 1 + random.Intn(maxH)
+	density := random.Float64() * maxDensity
+	g := newGrid(w, h, false)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			g[y][x] = random.Float64() < density
+		}
+	}
+	return g
+}
+
+// A map from rows of '.' for open and '@' for blocked cells
+func gridFromRows(rows ...string) [][]bool {
+	g := newGrid(len(rows[0]), len(rows), false)
+	for y, row := range(rows) {
+		for x, r := range(row) {
+			g[y][x] = r == '@'
+		}
+	}
+	return g
+}
+
+// A random node of the current grid and node model
+func randomNode(random *rand.Rand) Node {
+	maxX, maxY := maxNodeCoordinates()
+	return NewNode(random.Intn(maxX+1), random.Intn(maxY+1))
+}
+
+/*
+ * Why a path of moves cannot be followed from start to goal, or "" if it
+ * can. Whether an empty path is right is up to the caller.
+ */
+func pathProblem(path []Node, start, goal Node) string {
+	if len(path) == 0 {
+		return ""
+	}
+	if path[0] != start || path[len(path)-1] != goal {
+		return fmt.Sprintf("it runs from (%d,%d) to (%d,%d)", path[0].X, path[0].Y, path[len(path)-1].X, path[len(path)-1].Y)
+	}
+	for i := 0; i+1 < len(path); i++ {
+		if !moveAllowedSpec(path[i], path[i+1]) {
+			return fmt.Sprintf("step %d from (%d,%d) to (%d,%d) is not a move", i+1, path[i].X, path[i].Y, path[i+1].X, path[i+1].Y)
+		}
+	}
+	return ""
+}
+
+// The map with blocked cells as '@', for failure messages
+func gridString(g [][]bool) string {
+	s := ""
+	for _, row := range(g) {
+		for _, blocked := range(row) {
+			if blocked {
+				s += "@"
+			} else {
+				s += "."
+			}
+		}
+		s += "\n"
+	}
+	return s
+}
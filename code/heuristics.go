@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+// The heuristics that --heuristic can select
+var heuristicRegistry = []struct {
+	name      string
+	heuristic func(Node, Node) float64
+}{
+	{"octile",    octileHeuristic},
+	{"euclidean", StraightLineDist},
+	{"manhattan", manhattanHeuristic},
+	{"chebyshev", chebyshevHeuristic},
+	{"zero",      zeroHeuristic},
+	{"landmark",  landmarkHeuristic},
+}
+
+// The name of the heuristic selected with --heuristic, empty when every
+// algorithm uses its own
+var selectedHeuristic string
+
+func octileHeuristic(from, to Node) float64 {
+	dx := math.Abs(float64(from.X - to.X))
+	dy := math.Abs(float64(from.Y - to.Y))
+	return dx + dy + (SQRT2 - 2) * math.Min(dx, dy)
+}
+
+func manhattanHeuristic(from, to Node) float64 {
+	return math.Abs(float64(from.X - to.X)) + math.Abs(float64(from.Y - to.Y))
+}
+
+func chebyshevHeuristic(from, to Node) float64 {
+	return math.Max(math.Abs(float64(from.X - to.X)), math.Abs(float64(from.Y - to.Y)))
+}
+
+func zeroHeuristic(from, to Node) float64 {
+	return 0
+}
+
+func heuristicNames() []string {
+	names := []string{}
+	for _, entry := range(heuristicRegistry) {
+		names = append(names, entry.name)
+	}
+	return names
+}
+
+func MustParseHeuristic(name string) string {
+	name = strings.ToLower(name)
+	for _, entry := range(heuristicRegistry) {
+		if entry.name == name {
+			return name
+		}
+	}
+	fmt.Printf("Unknown heuristic \"%s\", accepted heuristics are \"%s\"\n", name, strings.Join(heuristicNames(), "\", \""))
+	os.Exit(1)
+	return ""
+}
+
+/*
+ * The heuristic selected with --heuristic, or the given default of the
+ * algorithm. The landmarks are selected first if needed.
+ */
+func searchHeuristic(defaultHeuristic func(Node, Node) float64) func(Node, Node) float64 {
+	if selectedHeuristic == "" {
+		return defaultHeuristic
+	}
+	if selectedHeuristic == "landmark" {
+		ensureLandmarks()
+	}
+	for _, entry := range(heuristicRegistry) {
+		if entry.name == selectedHeuristic {
+			return entry.heuristic
+		}
+	}
+	panic("Assertion failed: unknown heuristic")
+}
+
+/*
+ * Whether a heuristic never overestimates the distance between two nodes
+ * with the given connectivity, which A* needs to find shortest paths.
+ * Any-angle paths can be as short as the straight line, so only the
+ * heuristics that are not longer than it are admissible for them.
+ */
+func heuristicAdmissible(name string, c Connectivity, anyAngle bool) bool {
+	switch name {
+		case "euclidean", "chebyshev", "zero":
+			return true
+		case "octile", "landmark":
+			return !anyAngle
+		case "manhattan":
+			return !anyAngle && c == FourConnected
+	}
+	panic("Assertion failed: unknown heuristic")
+}
+
+/*
+ * Prints a warning if the selected heuristic can make an algorithm that
+ * finds shortest paths miss them with the given connectivity, and notes
+ * if the weight makes the paths longer or D* Lite ignores the landmarks.
+ */
+func warnAboutHeuristic(a Algorithm, c Connectivity) {
+	if heuristicWeight > 1 && a.supports("weight") {
+		fmt.Printf("Note: with --weight=%g the paths of %s may be up to %g times longer than optimal\n", heuristicWeight, a.Name, heuristicWeight)
+	}
+	if selectedHeuristic == "" || !a.supports("heuristic") || a.Optimality == Suboptimal {
+		return
+	}
+	if selectedHeuristic == "landmark" && a.Name == "dstarlite" {
+		fmt.Println("Note: dstarlite uses the default heuristic instead of landmark, whose distances change with the cells")
+		return
+	}
+	if !heuristicAdmissible(selectedHeuristic, c, a.Optimality == AnyAngle) {
+		fmt.Printf("Warning: the %s heuristic is not admissible for %s with %s-connectivity, so its paths may be longer than optimal\n",
+			selectedHeuristic, a.Name, connectivityName(c))
+	}
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"container/heap"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+)
+
+/*
+ * The abstract graph of HPA* (Botea, Müller and Schaeffer 2004). The nodes
+ * are divided into square clusters, and the abstract nodes are the nodes
+ * on both sides of the entrances between neighbouring clusters. Abstract
+ * nodes of neighbouring clusters are connected by a single move, and the
+ * abstract nodes of one cluster by their shortest paths inside it.
+ */
+type HPAGraph struct {
+	MapHash      uint64 // See gridHash
+	ClusterSize  int
+	Model        NodeModel
+	Connectivity Connectivity
+	Edges        map[Node][]HPAEdge
+}
+
+type HPAEdge struct {
+	To   Node
+	Cost float64
+}
+
+var hpaClusterSize = 16
+var hpaCacheDir    string // Where the graphs are saved, empty for no cache
+var hpaGraph       *HPAGraph // The graph of the current grid, nil until it is built
+var hpaFallbacks   int       // Searches since the graph was made that needed AStar
+
+// A hash of the grid and of the agent size, which decides where nodes are
+// open, to tell whether a saved graph belongs to it
+func gridHash() uint64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%dx%d:", len(grid[0]), len(grid))
+	if agentSize > 0 {
+		fmt.Fprintf(h, "agent %d:", agentSize)
+	}
+	for _, row := range(grid) {
+		for _, blocked := range(row) {
+			if blocked {
+				h.Write([]byte{1})
+			} else {
+				h.Write([]byte{0})
+			}
+		}
+	}
+	return h.Sum64()
+}
+
+func clusterOf(n Node) [2]int {
+	return [2]int{n.X / hpaClusterSize, n.Y / hpaClusterSize}
+}
+
+func canMove(from, to Node) bool {
+	for _, n := range(getTraversableNodes(from)) {
+		if n == to {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ * Builds the abstract graph of the current grid, or loads it from the
+ * cache directory if it was saved for the same map and settings. The
+ * cache file is named after the map file. Prints how the graph was made.
+ */
+func preprocessHPA(mapPath string) {
+	cachePath := ""
+	if hpaCacheDir != "" {
+		cachePath = filepath.Join(hpaCacheDir, filepath.Base(mapPath) + ".hpa")
+		loaded, err := LoadHPAGraph(cachePath)
+		if err == nil && loaded.MapHash == gridHash() && loaded.ClusterSize == hpaClusterSize &&
+		   loaded.Model == nodeModel && loaded.Connectivity == connectivity {
+			hpaGraph     = loaded
+			hpaFallbacks = 0
+			fmt.Printf("Loaded the HPA* graph from \"%s\"\n", cachePath)
+			return
+		}
+	}
+	hpaGraph = buildHPAGraph()
+	nodes, edges := len(hpaGraph.Edges), 0
+	for _, e := range(hpaGraph.Edges) {
+		edges += len(e)
+	}
+	fmt.Printf("Built the HPA* graph: %d abstract nodes, %d edges\n", nodes, edges)
+	if cachePath != "" {
+		err := SaveHPAGraph(hpaGraph, cachePath)
+		if err != nil {
+			fmt.Printf("Error writing HPA* graph \"%s\": %s\n", cachePath, err.Error())
+			os.Exit(1)
+		}
+	}
+}
+
+func buildHPAGraph() *HPAGraph {
+	hpaFallbacks = 0
+	graph := &HPAGraph{gridHash(), hpaClusterSize, nodeModel, connectivity, map[Node][]HPAEdge{}}
+	maxX, maxY := maxNodeCoordinates()
+	addEdge := func(from, to Node, cost float64) {
+		graph.Edges[from] = append(graph.Edges[from], HPAEdge{to, cost})
+	}
+
+	// Entrances are runs of orthogonal moves across a cluster border that
+	// are connected along the border on both sides. Short runs get one
+	// transition in the middle, long runs one at each end. Diagonal moves
+	// need no transitions: every model only allows a diagonal move where
+	// two orthogonal moves around an open cell make the same step.
+	addEntrances := func(crossings []Node, step Node) {
+		alongBorder := func(a, b Node) bool {
+			return b.X - a.X + b.Y - a.Y == 1 && canMove(a, b) &&
+			       canMove(NewNode(a.X + step.X, a.Y + step.Y), NewNode(b.X + step.X, b.Y + step.Y))
+		}
+		for i := 0; i < len(crossings); {
+			j := i
+			for j+1 < len(crossings) && alongBorder(crossings[j], crossings[j+1]) {
+				j++
+			}
+			transitions := []Node{crossings[(i+j)/2]}
+			if j - i + 1 >= 6 {
+				transitions = []Node{crossings[i], crossings[j]}
+			}
+			for _, a := range(transitions) {
+				b := NewNode(a.X + step.X, a.Y + step.Y)
+				addEdge(a, b, 1)
+				addEdge(b, a, 1)
+			}
+			i = j+1
+		}
+	}
+	for border := hpaClusterSize; border <= maxX; border += hpaClusterSize {
+		for top := 0; top <= maxY; top += hpaClusterSize {
+			crossings := []Node{}
+			for y := top; y < top + hpaClusterSize && y <= maxY; y++ {
+				if canMove(NewNode(border-1, y), NewNode(border, y)) {
+					crossings = append(crossings, NewNode(border-1, y))
+				}
+			}
+			addEntrances(crossings, NewNode(1, 0))
+		}
+	}
+	for border := hpaClusterSize; border <= maxY; border += hpaClusterSize {
+		for left := 0; left <= maxX; left += hpaClusterSize {
+			crossings := []Node{}
+			for x := left; x < left + hpaClusterSize && x <= maxX; x++ {
+				if canMove(NewNode(x, border-1), NewNode(x, border)) {
+					crossings = append(crossings, NewNode(x, border-1))
+				}
+			}
+			addEntrances(crossings, NewNode(0, 1))
+		}
+	}
+
+	// Connect the abstract nodes of each cluster
+	clusters := map[[2]int][]Node{}
+	for n := range(graph.Edges) {
+		clusters[clusterOf(n)] = append(clusters[clusterOf(n)], n)
+	}
+	for _, nodes := range(clusters) {
+		for _, from := range(nodes) {
+			dist, _ := clusterDijkstra(from, nodes)
+			for _, to := range(nodes) {
+				if d, found := dist[to]; found && to != from {
+					addEdge(from, to, d)
+				}
+			}
+		}
+	}
+	return graph
+}
+
+/*
+ * Dijkstra's algorithm that stays inside the cluster of the start and
+ * stops when all targets are reached. Returns the distances and parents.
+ */
+func clusterDijkstra(start Node, targets []Node) (map[Node]float64, map[Node]Node) {
+	cluster  := clusterOf(start)
+	dist     := map[Node]float64{start: 0}
+	parents  := map[Node]Node{}
+	done     := map[Node]bool{}
+	queue    := nodeQueue{{start, 0, 0}}
+	remaining := map[Node]bool{}
+	for _, t := range(targets) {
+		remaining[t] = true
+	}
+	for len(queue) > 0 && len(remaining) > 0 {
+		n := heap.Pop(&queue).(queuedNode).node
+		if done[n] {
+			continue
+		}
+		done[n] = true
+		delete(remaining, n)
+		for _, neighbour := range(getTraversableNodes(n)) {
+			if clusterOf(neighbour) != cluster || done[neighbour] {
+				continue
+			}
+			d := dist[n] + costToNeighbour(n, neighbour)
+			if old, found := dist[neighbour]; !found || d < old {
+				dist[neighbour]    = d
+				parents[neighbour] = n
+				heap.Push(&queue, queuedNode{neighbour, d, d})
+			}
+		}
+	}
+	return dist, parents
+}
+
+func clusterPath(from, to Node) []Node {
+	dist, parents := clusterDijkstra(from, []Node{to})
+	if _, found := dist[to]; !found {
+		return []Node{}
+	}
+	path := []Node{to}
+	for n := to; n != from; {
+		n = parents[n]
+		path = append([]Node{n}, path...)
+	}
+	return path
+}
+
+/*
+ * HPA*. Start and goal are connected to the abstract nodes of their
+ * clusters, the abstract graph is searched with A*, and every abstract
+ * edge is refined into a path inside its cluster. The paths are close
+ * to optimal but not always optimal. Start and goal in the same cluster
+ * are first tried with a search inside the cluster. The transitions
+ * connect the clusters wherever the moves do, so the abstract search
+ * should not fail for connected nodes. If it does, AStar finds the path
+ * and hpaFallbacks counts the search, so that the modes can report it.
+ */
+func HPAStar(start, goal Node) []Node {
+	if !sameComponent(start, goal) {
+		return []Node{}
+	}
+	if hpaGraph == nil || hpaGraph.ClusterSize != hpaClusterSize || hpaGraph.Model != nodeModel || hpaGraph.Connectivity != connectivity {
+		hpaGraph = buildHPAGraph()
+	}
+	if clusterOf(start) == clusterOf(goal) {
+		if path := clusterPath(start, goal); len(path) > 0 {
+			return path
+		}
+	}
+
+	// Links from the start and to the goal inside their clusters
+	links := map[Node][]HPAEdge{}
+	for _, end := range([]Node{start, goal}) {
+		abstract := []Node{}
+		for n := range(hpaGraph.Edges) {
+			if clusterOf(n) == clusterOf(end) {
+				abstract = append(abstract, n)
+			}
+		}
+		dist, _ := clusterDijkstra(end, abstract)
+		for _, n := range(abstract) {
+			if d, found := dist[n]; found {
+				if end == start {
+					links[start] = append(links[start], HPAEdge{n, d})
+				} else {
+					links[n] = append(links[n], HPAEdge{goal, d})
+				}
+			}
+		}
+	}
+
+	abstractPath := abstractGraphSearch(start, goal, hpaGraph.Edges, links)
+	if len(abstractPath) == 0 {
+		hpaFallbacks++
+		return AStar(start, goal)
+	}
+	path := []Node{start}
+	for i := 0; i < len(abstractPath)-1; i++ {
+		from, to := abstractPath[i], abstractPath[i+1]
+		if from == to {
+			continue
+		}
+		if clusterOf(from) != clusterOf(to) {
+			path = append(path, to) // A transition
+			continue
+		}
+		path = append(path, clusterPath(from, to)[1:]...)
+	}
+	return path
+}
+
+// Prints how many searches HPA* left to AStar since the last report
+func reportHPAFallbacks() {
+	if hpaFallbacks > 0 {
+		fmt.Printf("Note: HPA* fell back to A* in %d search(es) that the abstract graph did not connect\n", hpaFallbacks)
+		hpaFallbacks = 0
+	}
+}
+
+// A* over an abstract graph plus the start and goal links
+func abstractGraphSearch(start, goal Node, edges, links map[Node][]HPAEdge) []Node {
+	dist    := map[Node]float64{start: 0}
+	parents := map[Node]Node{}
+	done    := map[Node]bool{}
+	h       := searchHeuristic(gridHeuristic)
+	queue   := nodeQueue{{start, h(start, goal), 0}}
+	for len(queue) > 0 {
+		n := heap.Pop(&queue).(queuedNode).node
+		if done[n] {
+			continue
+		}
+		if n == goal {
+			path := []Node{goal}
+			for n != start {
+				n = parents[n]
+				path = append([]Node{n}, path...)
+			}
+			return path
+		}
+		done[n] = true
+		noteStoredNodes(len(dist) + len(parents) + len(done) + len(queue))
+		for _, out := range([][]HPAEdge{edges[n], links[n]}) {
+			for _, e := range(out) {
+				d := dist[n] + e.Cost
+				if old, found := dist[e.To]; !done[e.To] && (!found || d < old) {
+					dist[e.To]    = d
+					parents[e.To] = n
+					heap.Push(&queue, queuedNode{e.To, d + h(e.To, goal), d})
+				}
+			}
+		}
+	}
+	return []Node{}
+}
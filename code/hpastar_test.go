@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+/*
+ * Compares HPA* with A* between random nodes on random maps of up to 24x24
+ * cells with clusters of 4 nodes, in every node model and connectivity.
+ * The paths must be valid and found for the same queries, and may be
+ * longer than the A* paths but not shorter. The abstract graph must
+ * connect every start and goal that are connected, without falling back
+ * to A*.
+ */
+func TestHPAStar(t *testing.T) {
+	keepGlobals(t)
+	oldClusterSize := hpaClusterSize
+	t.Cleanup(func() {
+		hpaClusterSize = oldClusterSize
+	})
+	hpaClusterSize = 4
+	const epsilon = 1e-6
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		g := randomGrid(random, 24, 24, 0.4)
+		for _, model := range(allNodeModels) {
+			for _, c := range(allConnectivities) {
+				nodeModel, connectivity = model, c
+				setGrid(g)
+				for j := 0; j < 10; j++ {
+					start    := randomNode(random)
+					goal     := randomNode(random)
+					expected := AStar(start, goal)
+					path     := HPAStar(start, goal)
+					prefix   := fmt.Sprintf("map %d, %s model, %s-connectivity: HPA* path (%d,%d) -> (%d,%d)",
+						i, nodeModelName(model), connectivityName(c), start.X, start.Y, goal.X, goal.Y)
+					if problem := pathProblem(path, start, goal); problem != "" {
+						t.Errorf("%s: %s\n%s", prefix, problem, gridString(g))
+					}
+					if (len(path) > 0) != (len(expected) > 0) || PathLength(path) < PathLength(expected) - epsilon {
+						t.Errorf("%s has length %f, astar %f\n%s", prefix, PathLength(path), PathLength(expected), gridString(g))
+					}
+					if hpaFallbacks > 0 {
+						t.Errorf("%s fell back to A*\n%s", prefix, gridString(g))
+						hpaFallbacks = 0
+					}
+				}
+			}
+		}
+	}
+}
+
+/*
+ * Saves a graph in a cache directory and checks that it is only loaded
+ * again for the same map, cluster size, node model, connectivity and
+ * agent size. The saved graph has a node that no built graph has, which
+ * tells whether it was loaded or built again.
+ */
+func TestHPACache(t *testing.T) {
+	keepGlobals(t)
+	oldClusterSize, oldCacheDir := hpaClusterSize, hpaCacheDir
+	t.Cleanup(func() {
+		hpaClusterSize, hpaCacheDir = oldClusterSize, oldCacheDir
+	})
+	hpaCacheDir = t.TempDir()
+	random   := rand.New(rand.NewSource(1))
+	original := randomGrid(random, 24, 24, 0.2)
+	changed  := make([][]bool, len(original))
+	for y := range(original) {
+		changed[y] = append([]bool{}, original[y]...)
+	}
+	changed[0][0] = !changed[0][0]
+
+	settings := func(g [][]bool, clusterSize int, model NodeModel, c Connectivity, size int) {
+		hpaClusterSize, nodeModel, connectivity, agentSize = clusterSize, model, c, size
+		setGrid(g)
+	}
+	settings(original, 8, GridCorners, EightConnected, 0)
+	saved  := buildHPAGraph()
+	marker := NewNode(-1, -1)
+	saved.Edges[marker] = []HPAEdge{{marker, 1}}
+	if err := SaveHPAGraph(saved, filepath.Join(hpaCacheDir, "test.map.hpa")); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	cases := []struct {
+		name string
+		set  func()
+		load bool
+	}{
+		{"the same settings", func() { settings(original, 8, GridCorners, EightConnected, 0) }, true},
+		{"a changed cell", func() { settings(changed, 8, GridCorners, EightConnected, 0) }, false},
+		{"another cluster size", func() { settings(original, 4, GridCorners, EightConnected, 0) }, false},
+		{"another node model", func() { settings(original, 8, CellCenters, EightConnected, 0) }, false},
+		{"another connectivity", func() { settings(original, 8, GridCorners, FourConnected, 0) }, false},
+		{"another agent size", func() { settings(original, 8, GridCorners, EightConnected, 1) }, false},
+	}
+	for _, c := range(cases) {
+		c.set()
+		preprocessHPA("test.map")
+		if _, loaded := hpaGraph.Edges[marker]; loaded != c.load {
+			t.Errorf("%s: the saved graph was loaded %t, expected %t", c.name, loaded, c.load)
+		}
+		// A graph that was built again replaces the saved one
+		if !c.load {
+			if err := SaveHPAGraph(saved, filepath.Join(hpaCacheDir, "test.map.hpa")); err != nil {
+				t.Fatal(err.Error())
+			}
+		}
+	}
+}
+
+/*
+ * The transitions of a 16x16 map with clusters of 8 cell centers and
+ * 4-connectivity. An open border of 8 cells is one long entrance with a
+ * transition at each end, and a blocked cell on a border splits it into
+ * short entrances with one transition in the middle of each.
+ */
+func TestHPAEntrances(t *testing.T) {
+	keepGlobals(t)
+	oldClusterSize := hpaClusterSize
+	t.Cleanup(func() {
+		hpaClusterSize = oldClusterSize
+	})
+	hpaClusterSize = 8
+	nodeModel, connectivity = CellCenters, FourConnected
+	g := newGrid(16, 16, false)
+	g[10][8] = true
+	g[8][3]  = true
+	setGrid(g)
+	graph := buildHPAGraph()
+	got := []string{}
+	for from, edges := range(graph.Edges) {
+		for _, e := range(edges) {
+			if clusterOf(from) != clusterOf(e.To) && from.X + from.Y < e.To.X + e.To.Y {
+				got = append(got, fmt.Sprintf("(%d,%d)-(%d,%d)", from.X, from.Y, e.To.X, e.To.Y))
+			}
+		}
+	}
+	sort.Strings(got)
+	expected := []string{
+		"(1,7)-(1,8)", "(15,7)-(15,8)", "(5,7)-(5,8)", "(7,0)-(8,0)",
+		"(7,13)-(8,13)", "(7,7)-(8,7)", "(7,8)-(8,8)", "(8,7)-(8,8)",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("the transitions are %v, expected %v", got, expected)
+	}
+}
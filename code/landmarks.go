@@ -0,0 +1,238 @@
+package main
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"time"
+)
+
+/*
+ * Landmarks for ALT (Goldberg and Harrelson 2005): A* with landmarks and
+ * the triangle inequality. The exact distances from a few landmarks to
+ * every node give a lower bound on the distance between any two nodes,
+ * |d(L,a) - d(L,b)|, which is much better than the octile distance when
+ * walls make the paths wind, like in mazes. The distances are stored as
+ * float32 to halve the memory, indexed by y*Width + x.
+ */
+type Landmarks struct {
+	MapHash       uint64 // See gridHash
+	Model         NodeModel
+	Connectivity  Connectivity
+	Width, Height int // The number of node columns and rows
+	Nodes         []Node
+	Distances     [][]float32 // One table per landmark, +Inf for unreachable nodes
+}
+
+var landmarkData  *Landmarks // The landmarks of the current grid, nil until they are selected
+var landmarkPath  string     // Where alt loads its landmarks from, empty to select them
+var landmarkCount = 8        // How many landmarks alt uses
+
+func (l *Landmarks) index(n Node) int {
+	return n.Y * l.Width + n.X
+}
+
+// Bytes used by the distance tables
+func (l *Landmarks) tableSize() int {
+	return len(l.Distances) * l.Width * l.Height * 4
+}
+
+/*
+ * Dijkstra's algorithm from one node to every node of the map. Returns
+ * the distances indexed like the landmark tables. Every sum is rounded
+ * down to a float32, so the distances never exceed the true ones and two
+ * neighbours never differ by more than the cost of the move between
+ * them, which keeps the ALT bound admissible and consistent.
+ */
+func distancesFrom(start Node) []float32 {
+	maxX, maxY := maxNodeCoordinates()
+	width := maxX + 1
+	dist  := make([]float64, width * (maxY+1))
+	for i := range(dist) {
+		dist[i] = math.Inf(1)
+	}
+	dist[start.Y * width + start.X] = 0
+	queue := nodeQueue{{start, 0, 0}}
+	for len(queue) > 0 {
+		entry := heap.Pop(&queue).(queuedNode)
+		n := entry.node
+		if entry.g > dist[n.Y * width + n.X] {
+			continue // Outdated entry
+		}
+		for _, neighbour := range(getTraversableNodes(n)) {
+			d := float64(roundDown32(entry.g + costToNeighbour(n, neighbour)))
+			if i := neighbour.Y * width + neighbour.X; d < dist[i] {
+				dist[i] = d
+				heap.Push(&queue, queuedNode{neighbour, d, d})
+			}
+		}
+	}
+	distances := make([]float32, len(dist))
+	for i, d := range(dist) {
+		distances[i] = float32(d)
+	}
+	return distances
+}
+
+// The largest float32 that is not larger than d, for d >= 0
+func roundDown32(d float64) float32 {
+	f := float32(d)
+	if float64(f) > d {
+		f = math.Nextafter32(f, 0)
+	}
+	return f
+}
+
+// A random node of the largest component
+func randomNodeOfLargestComponent(random *rand.Rand) (Node, error) {
+	if len(componentSizes) == 0 {
+		return Node{}, errors.New("The map has no open nodes")
+	}
+	largest := componentsBySize()[0]
+	maxX, maxY := maxNodeCoordinates()
+	for {
+		n := NewNode(random.Intn(maxX+1), random.Intn(maxY+1))
+		if componentOf(n) == largest {
+			return n, nil
+		}
+	}
+}
+
+/*
+ * Selects n landmarks in the largest component and computes their
+ * distances. The strategy "random" picks random nodes. "farthest" starts
+ * at the node farthest from a random node, and then repeatedly picks the
+ * node farthest from all landmarks so far, which spreads them along the
+ * edges of the map where they give the best bounds. After each landmark,
+ * progress is called with the landmarks so far and the time it took.
+ */
+func SelectLandmarks(n int, strategy string, seed int64, progress func(*Landmarks, time.Duration)) (*Landmarks, error) {
+	if strategy != "farthest" && strategy != "random" {
+		msg := fmt.Sprintf("Unknown strategy \"%s\", accepted strategies are \"farthest\" and \"random\"", strategy)
+		return nil, errors.New(msg)
+	}
+	maxX, maxY := maxNodeCoordinates()
+	l := &Landmarks{gridHash(), nodeModel, connectivity, maxX+1, maxY+1, []Node{}, [][]float32{}}
+	random := rand.New(rand.NewSource(seed))
+	before := time.Now()
+
+	// The distance from each node to the closest landmark so far
+	var closest []float32
+	if strategy == "farthest" {
+		from, err := randomNodeOfLargestComponent(random)
+		if err != nil {
+			return nil, err
+		}
+		closest = distancesFrom(from)
+	}
+	for len(l.Nodes) < n {
+		var next Node
+		if strategy == "random" {
+			var err error
+			next, err = randomNodeOfLargestComponent(random)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			farthest := float32(-1)
+			for i, d := range(closest) {
+				if !math.IsInf(float64(d), 1) && d > farthest {
+					farthest = d
+					next = NewNode(i % l.Width, i / l.Width)
+				}
+			}
+		}
+		distances := distancesFrom(next)
+		l.Nodes     = append(l.Nodes, next)
+		l.Distances = append(l.Distances, distances)
+		if strategy == "farthest" {
+			if len(l.Nodes) == 1 {
+				closest = distances // Forget the random node
+			} else {
+				for i, d := range(distances) {
+					closest[i] = float32(math.Min(float64(closest[i]), float64(d)))
+				}
+			}
+		}
+		if progress != nil {
+			progress(l, time.Since(before))
+		}
+	}
+	return l, nil
+}
+
+/*
+ * The ALT lower bound on the distance between two nodes: the largest
+ * difference of their distances to a landmark, or the octile distance if
+ * that is larger. Landmarks that cannot reach both nodes are skipped. The
+ * tables are rounded down like in distancesFrom, and the difference of two
+ * float32 values is exact in a float64, so the bound needs no slack.
+ */
+func landmarkHeuristic(n, goal Node) float64 {
+	h := gridHeuristic(n, goal)
+	ni, gi := landmarkData.index(n), landmarkData.index(goal)
+	for i := 0; i < len(landmarkData.Distances) && i < landmarkCount; i++ {
+		a := float64(landmarkData.Distances[i][ni])
+		b := float64(landmarkData.Distances[i][gi])
+		if math.IsInf(a, 1) || math.IsInf(b, 1) {
+			continue
+		}
+		h = math.Max(h, math.Abs(a - b))
+	}
+	return h
+}
+
+/*
+ * Loads the landmarks given by --landmarks, or selects --landmark-count
+ * landmarks with the farthest strategy. Prints how many are used and the
+ * memory of their tables.
+ */
+func preprocessLandmarks(mapPath string) {
+	if landmarkPath != "" {
+		loaded, err := LoadLandmarks(landmarkPath)
+		if err != nil {
+			fmt.Printf("Error reading landmarks file \"%s\": %s\n", landmarkPath, err.Error())
+			os.Exit(1)
+		}
+		maxX, maxY := maxNodeCoordinates()
+		if loaded.MapHash != gridHash() || loaded.Model != nodeModel || loaded.Connectivity != connectivity ||
+		   loaded.Width != maxX+1 || loaded.Height != maxY+1 {
+			fmt.Printf("The landmarks file \"%s\" was made for another map, node model or connectivity\n", landmarkPath)
+			os.Exit(1)
+		}
+		landmarkData = loaded
+	} else {
+		selected, err := SelectLandmarks(landmarkCount, "farthest", 1, nil)
+		if err != nil {
+			fmt.Printf("Error selecting landmarks: %s\n", err.Error())
+			os.Exit(1)
+		}
+		landmarkData = selected
+	}
+	used := int(math.Min(float64(len(landmarkData.Nodes)), float64(landmarkCount)))
+	fmt.Printf("Using %d landmark(s), their tables take %.2fMB\n", used, float64(used * landmarkData.Width * landmarkData.Height * 4)/1e6)
+}
+
+// Selects the landmarks if they are missing or were made with other settings
+func ensureLandmarks() {
+	if landmarkData == nil || landmarkData.Model != nodeModel || landmarkData.Connectivity != connectivity {
+		preprocessLandmarks("")
+	}
+}
+
+// A* with the ALT heuristic, see landmarkHeuristic. The same as astar with
+// --heuristic=landmark.
+func ALTAStar(start, goal Node) []Node {
+	if !sameComponent(start, goal) {
+		return []Node{}
+	}
+	ensureLandmarks()
+	resetPathfindingStructures()
+	heuristic = func(from, to Node) float64 {
+		return heuristicWeight * landmarkHeuristic(from, to)
+	}
+	return findPath(start, goal)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+/*
+ * Selects landmarks with both strategies on random maps of up to 16x16
+ * cells, in every node model and connectivity, and checks that the ALT
+ * bound never exceeds the length of the shortest path between random
+ * connected nodes, and that ALT finds paths as short as Dijkstra's.
+ */
+func TestLandmarkAdmissibility(t *testing.T) {
+	keepGlobals(t)
+	oldCount := landmarkCount
+	t.Cleanup(func() {
+		landmarkCount = oldCount
+	})
+	landmarkCount = 4
+	const epsilon = 1e-9
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 50; i++ {
+		g := randomGrid(random, 16, 16, 0.4)
+		for _, model := range(allNodeModels) {
+			for _, c := range(allConnectivities) {
+				nodeModel, connectivity = model, c
+				setGrid(g)
+				for _, strategy := range([]string{"farthest", "random"}) {
+					selected, err := SelectLandmarks(landmarkCount, strategy, int64(i), nil)
+					if err != nil {
+						continue // No open nodes
+					}
+					landmarkData = selected
+					prefix := fmt.Sprintf("map %d, %s model, %s-connectivity, %s landmarks", i, nodeModelName(model), connectivityName(c), strategy)
+					for j := 0; j < 20; j++ {
+						start := randomNode(random)
+						goal  := randomNode(random)
+						path  := Dijkstra(start, goal)
+						if len(path) == 0 {
+							continue
+						}
+						length := PathLength(path)
+						if h := landmarkHeuristic(start, goal); h > length + epsilon {
+							t.Errorf("%s: the bound of (%d,%d) -> (%d,%d) is %f, the shortest path has length %f\n%s",
+								prefix, start.X, start.Y, goal.X, goal.Y, h, length, gridString(g))
+						}
+						if alt := PathLength(ALTAStar(start, goal)); math.Abs(alt - length) > 1e-6 {
+							t.Errorf("%s: ALT path (%d,%d) -> (%d,%d) has length %f, expected %f\n%s",
+								prefix, start.X, start.Y, goal.X, goal.Y, alt, length, gridString(g))
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+/*
+ * Checks that the ALT bound is consistent, h(a) <= cost(a,b) + h(b) for
+ * every move from a to b, towards random goals on random maps of up to
+ * 16x16 cells in every node model and connectivity. The epsilon only
+ * allows for the rounding of the octile distance in float64.
+ */
+func TestLandmarkConsistency(t *testing.T) {
+	keepGlobals(t)
+	oldCount := landmarkCount
+	t.Cleanup(func() {
+		landmarkCount = oldCount
+	})
+	landmarkCount = 4
+	const epsilon = 1e-12
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 30; i++ {
+		g := randomGrid(random, 16, 16, 0.3)
+		for _, model := range(allNodeModels) {
+			for _, c := range(allConnectivities) {
+				nodeModel, connectivity = model, c
+				setGrid(g)
+				for _, strategy := range([]string{"farthest", "random"}) {
+					selected, err := SelectLandmarks(landmarkCount, strategy, int64(i), nil)
+					if err != nil {
+						continue // No open nodes
+					}
+					landmarkData = selected
+					maxX, maxY := maxNodeCoordinates()
+					for j := 0; j < 5; j++ {
+						goal := randomNode(random)
+						for y := 0; y <= maxY; y++ {
+							for x := 0; x <= maxX; x++ {
+								a := NewNode(x, y)
+								for _, b := range(getTraversableNodes(a)) {
+									ha, hb := landmarkHeuristic(a, goal), landmarkHeuristic(b, goal)
+									if cost := costToNeighbour(a, b); ha > cost + hb + epsilon {
+										t.Fatalf("map %d, %s model, %s-connectivity, %s landmarks: towards (%d,%d) the bound is %.17g at (%d,%d) and %.17g at (%d,%d), one move of %g away\n%s",
+											i, nodeModelName(model), connectivityName(c), strategy, goal.X, goal.Y, ha, a.X, a.Y, hb, b.X, b.Y, cost, gridString(g))
+									}
+								}
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+/*
+ * A U-shaped corridor of cell centers with 4-connectivity. The farthest
+ * strategy puts two landmarks at the ends of the U, which makes the ALT
+ * bound between nodes of the corridor their exact distance, while the
+ * Manhattan distance only crosses the wall. The tables hold the distances
+ * rounded down to float32.
+ */
+func TestLandmarksFarthest(t *testing.T) {
+	keepGlobals(t)
+	oldCount := landmarkCount
+	t.Cleanup(func() {
+		landmarkCount = oldCount
+	})
+	landmarkCount = 2
+	nodeModel, connectivity = CellCenters, FourConnected
+	setGrid(gridFromRows(
+		".@.",
+		".@.",
+		".@.",
+		"...",
+	))
+	for seed := int64(0); seed < 5; seed++ {
+		selected, err := SelectLandmarks(2, "farthest", seed, nil)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		ends := fmt.Sprint(selected.Nodes)
+		if ends != fmt.Sprint([]Node{NewNode(0, 0), NewNode(2, 0)}) && ends != fmt.Sprint([]Node{NewNode(2, 0), NewNode(0, 0)}) {
+			t.Errorf("seed %d: the farthest landmarks are %v, expected the ends of the corridor", seed, selected.Nodes)
+		}
+		landmarkData = selected
+		for _, q := range([][3]int{{0, 0, 8}, {0, 1, 7}, {1, 3, 4}, {2, 1, 1}}) {
+			from := NewNode(q[0], q[1])
+			if h := landmarkHeuristic(from, NewNode(2, 0)); h != float64(q[2]) {
+				t.Errorf("seed %d: the ALT bound from (%d,%d) to (2,0) is %f, expected %d", seed, q[0], q[1], h, q[2])
+			}
+		}
+	}
+
+	for _, d := range([]float64{0.1, SQRT2, 1e6 + SQRT2}) {
+		if f := roundDown32(d); float64(f) > d || float64(math.Nextafter32(f, float32(math.Inf(1)))) <= d {
+			t.Errorf("%g is rounded down to %g", d, f)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
     "bufio"
+    "encoding/binary"
     "fmt"
     "os"
 	"errors"
@@ -197,3 +198,211 @@ func LoadMap(path string) ([][]bool, error) {
 	}
 	return grid, nil
 }
+
+/*
+ * Reads a map changes file. Each line is one of the following, and
+ * empty lines and lines starting with '#' are skipped:
+ * block x y
+ * open x y
+ * move x y
+ * replan
+ * Returns a non-nil error if something goes wrong.
+ */
+func LoadChanges(path string) ([]MapChange, error) {
+	changes := []MapChange{}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return changes, errors.New("Could not open file "+err.Error())
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	lineCounter := 0
+	for scanner.Scan() {
+		lineCounter++
+		splits := strings.Fields(scanner.Text())
+		if len(splits) == 0 || strings.HasPrefix(splits[0], "#") {
+			continue
+		}
+
+		change := MapChange{}
+		switch splits[0] {
+			case "block":
+				change.Kind = BlockCell
+			case "open":
+				change.Kind = OpenCell
+			case "move":
+				change.Kind = MoveStart
+			case "replan":
+				change.Kind = Replan
+			default:
+				msg := fmt.Sprintf("Unknown change \"%s\" on line %d", splits[0], lineCounter)
+				return changes, errors.New(msg)
+		}
+
+		if change.Kind == Replan {
+			if len(splits) != 1 {
+				msg := fmt.Sprintf("Expected no coordinates on line %d", lineCounter)
+				return changes, errors.New(msg)
+			}
+		} else {
+			if len(splits) != 3 {
+				msg := fmt.Sprintf("Expected 2 coordinates on line %d", lineCounter)
+				return changes, errors.New(msg)
+			}
+			change.X, err = strconv.Atoi(splits[1])
+			if err != nil {
+				msg := fmt.Sprintf("Non-int x-coordinate \"%s\" on line %d", splits[1], lineCounter)
+				return changes, errors.New(msg)
+			}
+			change.Y, err = strconv.Atoi(splits[2])
+			if err != nil {
+				msg := fmt.Sprintf("Non-int y-coordinate \"%s\" on line %d", splits[2], lineCounter)
+				return changes, errors.New(msg)
+			}
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+/*
+ * Reads a cell costs file: one line per row of the map, with the costs of
+ * the cells in the row separated by whitespace. Costs must be positive.
+ * Returns a non-nil error if something goes wrong.
+ */
+func LoadCosts(path string) ([][]float64, error) {
+	costs := [][]float64{}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return costs, errors.New("Could not open file "+err.Error())
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024) // Rows of large maps are long
+	lineCounter := 0
+	for scanner.Scan() {
+		lineCounter++
+		row := []float64{}
+		for _, field := range(strings.Fields(scanner.Text())) {
+			cost, err := strconv.ParseFloat(field, 64)
+			if err != nil || cost <= 0 {
+				msg := fmt.Sprintf("Bad cost \"%s\" on line %d", field, lineCounter)
+				return costs, errors.New(msg)
+			}
+			row = append(row, cost)
+		}
+		if len(costs) > 0 && len(row) != len(costs[0]) {
+			msg := fmt.Sprintf("Expected %d costs on line %d", len(costs[0]), lineCounter)
+			return costs, errors.New(msg)
+		}
+		costs = append(costs, row)
+	}
+	if len(costs) == 0 || len(costs[0]) == 0 {
+		return costs, errors.New("No costs")
+	}
+	return costs, nil
+}
+
+/*
+ * Reads an HPA* graph written by SaveHPAGraph.
+ * Returns a non-nil error if something goes wrong.
+ */
+func LoadHPAGraph(path string) (*HPAGraph, error) {
+	graph := &HPAGraph{Edges: map[Node][]HPAEdge{}}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return graph, errors.New("Could not open file "+err.Error())
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Scan()
+	if line := scanner.Text(); line != "hpa 1" {
+		msg := fmt.Sprintf("Bad first line \"%s\"", line)
+		return graph, errors.New(msg)
+	}
+	var model, conn int
+	header := []struct {
+		format string
+		value  interface{}
+	}{
+		{"hash %d", &graph.MapHash},
+		{"cluster-size %d", &graph.ClusterSize},
+		{"model %d", &model},
+		{"connectivity %d", &conn},
+	}
+	for i, h := range(header) {
+		scanner.Scan()
+		if _, err := fmt.Sscanf(scanner.Text(), h.format, h.value); err != nil {
+			msg := fmt.Sprintf("Bad header line %d \"%s\"", i+2, scanner.Text())
+			return graph, errors.New(msg)
+		}
+	}
+	graph.Model        = NodeModel(model)
+	graph.Connectivity = Connectivity(conn)
+
+	lineCounter := len(header) + 2
+	for scanner.Scan() {
+		var from, to Node
+		var cost float64
+		_, err := fmt.Sscanf(scanner.Text(), "%d %d %d %d %g", &from.X, &from.Y, &to.X, &to.Y, &cost)
+		if err != nil {
+			msg := fmt.Sprintf("Bad edge on line %d", lineCounter)
+			return graph, errors.New(msg)
+		}
+		from = NewNode(from.X, from.Y)
+		graph.Edges[from] = append(graph.Edges[from], HPAEdge{NewNode(to.X, to.Y), cost})
+		lineCounter++
+	}
+	return graph, nil
+}
+
+/*
+ * Reads landmarks written by SaveLandmarks.
+ * Returns a non-nil error if something goes wrong.
+ */
+func LoadLandmarks(path string) (*Landmarks, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.New("Could not open file "+err.Error())
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var magic [4]byte
+	var hash uint64
+	var header [5]int32 // Model, connectivity, width, height and count
+	if binary.Read(reader, binary.LittleEndian, &magic) != nil || string(magic[:]) != "ALT1" {
+		return nil, errors.New("Not a landmarks file")
+	}
+	if binary.Read(reader, binary.LittleEndian, &hash) != nil || binary.Read(reader, binary.LittleEndian, &header) != nil {
+		return nil, errors.New("Truncated header")
+	}
+	if header[2] < 1 || header[3] < 1 || header[4] < 0 {
+		msg := fmt.Sprintf("Bad size %dx%d with %d landmark(s)", header[2], header[3], header[4])
+		return nil, errors.New(msg)
+	}
+
+	l := &Landmarks{hash, NodeModel(header[0]), Connectivity(header[1]), int(header[2]), int(header[3]), []Node{}, [][]float32{}}
+	for i := 0; i < int(header[4]); i++ {
+		var xy [2]int32
+		if binary.Read(reader, binary.LittleEndian, &xy) != nil {
+			return nil, errors.New("Truncated landmark list")
+		}
+		l.Nodes = append(l.Nodes, NewNode(int(xy[0]), int(xy[1])))
+	}
+	for i := 0; i < int(header[4]); i++ {
+		distances := make([]float32, l.Width * l.Height)
+		if binary.Read(reader, binary.LittleEndian, distances) != nil {
+			msg := fmt.Sprintf("Truncated distance table %d", i+1)
+			return nil, errors.New(msg)
+		}
+		l.Distances = append(l.Distances, distances)
+	}
+	return l, nil
+}
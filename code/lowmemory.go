@@ -0,0 +1,138 @@
+package main
+
+import (
+	"container/list"
+	"math"
+)
+
+/*
+ * IDA* (Korf 1985). Depth-first searches that cut off paths whose f
+ * score exceeds a threshold, which starts at the heuristic of the start
+ * and grows to the lowest f score that was cut off in the previous
+ * iteration. Only the current path is stored, so memory grows with the
+ * path length instead of the map size, but without a closed list the
+ * many equally long paths of a grid are searched again and again. This
+ * is only practical for short scenarios.
+ */
+func IDAStar(start, goal Node) []Node {
+	if !sameComponent(start, goal) {
+		return []Node{}
+	}
+	path      := []Node{start}
+	onPath    := map[Node]bool{start: true} // Avoids cycles along the current path
+	h         := searchHeuristic(gridHeuristic)
+	threshold := h(start, goal)
+	for {
+		next, found := idaSearch(&path, onPath, h, 0, threshold, goal)
+		if found {
+			return path
+		}
+		if math.IsInf(next, 1) {
+			return []Node{}
+		}
+		threshold = next
+	}
+}
+
+// Returns whether the goal was found below the last node of the path, and
+// otherwise the lowest f score that was over the threshold
+func idaSearch(path *[]Node, onPath map[Node]bool, h func(Node, Node) float64, g, threshold float64, goal Node) (float64, bool) {
+	noteStoredNodes(len(*path) + len(onPath))
+	node := (*path)[len(*path)-1]
+	f := g + h(node, goal)
+	if f > threshold {
+		return f, false
+	}
+	if node == goal {
+		return f, true
+	}
+	lowest := math.Inf(1)
+	for _, neighbour := range(getTraversableNodes(node)) {
+		if onPath[neighbour] {
+			continue
+		}
+		*path = append(*path, neighbour)
+		onPath[neighbour] = true
+		next, found := idaSearch(path, onPath, h, g + costToNeighbour(node, neighbour), threshold, goal)
+		if found {
+			return next, true
+		}
+		lowest = math.Min(lowest, next)
+		*path = (*path)[:len(*path)-1]
+		delete(onPath, neighbour)
+	}
+	return lowest, false
+}
+
+// The g score and parent of a node seen by Fringe Search
+type fringeEntry struct {
+	g      float64
+	parent Node
+}
+
+/*
+ * Fringe Search (Björnsson et al. 2005). Like IDA* it visits nodes in
+ * iterations with a growing f limit, but it keeps the fringe of the
+ * last iteration in a list so that the next one continues from there,
+ * and caches the g score and parent of every seen node. Children are
+ * visited right after their parent in the same iteration. The list and
+ * the cache replace the open, closed, g, f and timestamp maps of A*, and
+ * the fringe is not kept sorted.
+ */
+func FringeSearch(start, goal Node) []Node {
+	if !sameComponent(start, goal) {
+		return []Node{}
+	}
+	fringe   := list.New()
+	elements := map[Node]*list.Element{start: fringe.PushBack(start)}
+	cache    := map[Node]fringeEntry{start: {0, start}}
+	h        := searchHeuristic(gridHeuristic)
+	limit    := h(start, goal)
+
+	for fringe.Len() > 0 {
+		lowest := math.Inf(1)
+		for e := fringe.Front(); e != nil; {
+			noteStoredNodes(fringe.Len() + len(elements) + len(cache))
+			node  := e.Value.(Node)
+			entry := cache[node]
+			f := entry.g + h(node, goal)
+			if f > limit {
+				lowest = math.Min(lowest, f)
+				e = e.Next()
+				continue
+			}
+			if node == goal {
+				return fringePath(cache, start, goal)
+			}
+			for _, neighbour := range(getTraversableNodes(node)) {
+				g := entry.g + costToNeighbour(node, neighbour)
+				if seen, found := cache[neighbour]; found && g >= seen.g {
+					continue
+				}
+				if old, found := elements[neighbour]; found {
+					fringe.Remove(old)
+				}
+				elements[neighbour] = fringe.InsertAfter(neighbour, e)
+				cache[neighbour]    = fringeEntry{g, node}
+			}
+			next := e.Next()
+			fringe.Remove(e)
+			delete(elements, node)
+			e = next
+		}
+		limit = lowest
+	}
+	return []Node{}
+}
+
+func fringePath(cache map[Node]fringeEntry, start, goal Node) []Node {
+	path := []Node{}
+	for node := goal; node != start; node = cache[node].parent {
+		path = append(path, node)
+	}
+	path = append(path, start)
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"testing"
+)
+
+/*
+ * Compares IDA* and Fringe Search with A* between random nodes on random
+ * maps of up to 16x16 cells, in every node model and connectivity: the
+ * paths must be valid and as short, and found for the same queries.
+ */
+func TestLowMemorySearch(t *testing.T) {
+	keepGlobals(t)
+	const epsilon = 1e-6
+	searches := map[string]func(Node, Node) []Node{"idastar": IDAStar, "fringe": FringeSearch}
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		g := randomGrid(random, 16, 16, 0.4)
+		for _, model := range(allNodeModels) {
+			for _, c := range(allConnectivities) {
+				nodeModel, connectivity = model, c
+				setGrid(g)
+				for j := 0; j < 5; j++ {
+					start    := randomNode(random)
+					goal     := randomNode(random)
+					expected := AStar(start, goal)
+					for _, name := range([]string{"idastar", "fringe"}) {
+						prefix := fmt.Sprintf("map %d, %s model, %s-connectivity: %s path (%d,%d) -> (%d,%d)",
+							i, nodeModelName(model), connectivityName(c), name, start.X, start.Y, goal.X, goal.Y)
+						path := searches[name](start, goal)
+						if problem := pathProblem(path, start, goal); problem != "" {
+							t.Errorf("%s: %s\n%s", prefix, problem, gridString(g))
+						}
+						if (len(path) > 0) != (len(expected) > 0) || math.Abs(PathLength(path) - PathLength(expected)) > epsilon {
+							t.Errorf("%s has length %f, astar %f\n%s", prefix, PathLength(path), PathLength(expected), gridString(g))
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+/*
+ * The goal just below the start, behind the wall of a box whose only
+ * ways out are at its sides, between cell centers with 4-connectivity.
+ * Both searches must find the way around, and the thresholds of IDA*
+ * must grow until its path goes out of the box. IDA* keeps only the
+ * current path and whether a node is on it, and Fringe Search keeps
+ * fewer nodes than A* on this map.
+ */
+func TestLowMemoryDetour(t *testing.T) {
+	keepGlobals(t)
+	nodeModel, connectivity = CellCenters, FourConnected
+	setGrid(gridFromRows(
+		"..........",
+		".@@@@@@@@.",
+		".@......@.",
+		".@.@@@@.@.",
+		"......@...",
+	))
+	start, goal := NewNode(4, 2), NewNode(4, 4)
+	expected := fmt.Sprint([]Node{start, NewNode(3, 2), NewNode(2, 2), NewNode(2, 3), NewNode(2, 4), NewNode(3, 4), goal})
+	peakStoredNodes = 0
+	AStar(start, goal)
+	aStarPeak := peakStoredNodes
+	for name, search := range(map[string]func(Node, Node) []Node{"idastar": IDAStar, "fringe": FringeSearch}) {
+		peakStoredNodes = 0
+		path := search(start, goal)
+		if fmt.Sprint(path) != expected {
+			t.Errorf("the %s path is %v, expected %s", name, path, expected)
+		}
+		if name == "idastar" && peakStoredNodes != 2 * len(path) {
+			t.Errorf("idastar stored %d nodes at most for a path of %d nodes", peakStoredNodes, len(path))
+		}
+		if name == "fringe" && peakStoredNodes >= aStarPeak {
+			t.Errorf("fringe stored %d nodes at most, astar %d", peakStoredNodes, aStarPeak)
+		}
+	}
+}
+
+/*
+ * Compares IDA* and Fringe Search with A* and the optimal lengths in 5
+ * scenarios of up to 32 cells of each scenarios file under ../maps,
+ * between cell centers without corner cutting like the files. IDA* takes
+ * too long on longer scenarios of the 512x512 maps, and -short skips the
+ * test.
+ */
+func TestLowMemoryScenarios(t *testing.T) {
+	if testing.Short() {
+		t.Skip("searches the 512x512 maps of ../maps")
+	}
+	keepGlobals(t)
+	nodeModel, connectivity = CellCenters, EightConnectedNoCornerCutting
+	const epsilon = 1e-4
+	paths, _ := filepath.Glob("../maps/*/*.scen")
+	if len(paths) == 0 {
+		t.Fatal("No scenarios files in ../maps")
+	}
+	searches := map[string]func(Node, Node) []Node{"idastar": IDAStar, "fringe": FringeSearch}
+	for _, path := range(paths) {
+		scenarios, err := LoadScenarios(path)
+		if err != nil {
+			t.Fatalf("%s: %s", path, err.Error())
+		}
+		short := []Scenario{}
+		for _, s := range(scenarios) {
+			if s.OptimalLength <= 32 {
+				short = append(short, s)
+			}
+		}
+		if len(short) == 0 {
+			t.Fatalf("%s has no scenarios of up to 32 cells", path)
+		}
+		loaded, err := LoadMap(filepath.Join(filepath.Dir(path), short[0].MapName))
+		if err != nil {
+			t.Fatalf("%s: %s", path, err.Error())
+		}
+		setGrid(loaded)
+		for _, s := range(selectScenarios(short, 5)) {
+			expected := PathLength(AStar(s.Start, s.Goal))
+			for _, name := range([]string{"idastar", "fringe"}) {
+				length := PathLength(searches[name](s.Start, s.Goal))
+				if math.Abs(length - expected) > epsilon || math.Abs(length - s.OptimalLength) > epsilon {
+					t.Errorf("%s: (%d,%d) -> (%d,%d) has length %f with %s, %f with astar, the optimal length is %f",
+						path, s.Start.X, s.Start.Y, s.Goal.X, s.Goal.Y, length, name, expected, s.OptimalLength)
+				}
+			}
+		}
+	}
+}
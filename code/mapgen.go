@@ -0,0 +1,265 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+var mapGenerators = []string{"random", "rooms", "caves", "maze"}
+
+/*
+ * Generates a grid with the named generator. The meaning of param
+ * depends on the generator:
+ * random: ratio of blocked cells (default 0.25)
+ * rooms:  width of the square rooms (default 16)
+ * caves:  ratio of blocked cells before smoothing (default 0.45)
+ * maze:   corridor width (default 1)
+ * A negative param selects the default.
+ * Returns a non-nil error if something goes wrong.
+ */
+func GenerateMap(generator string, width, height int, param float64, seed int64) ([][]bool, error) {
+	if width < 1 || height < 1 {
+		return [][]bool{}, errors.New("Width and height must be positive")
+	}
+	rng := rand.New(rand.NewSource(seed))
+	switch strings.ToLower(generator) {
+		case "random":
+			if param < 0 {
+				param = 0.25
+			}
+			if param > 1 {
+				return [][]bool{}, errors.New("Density must be between 0 and 1")
+			}
+			return generateRandomMap(width, height, param, rng), nil
+		case "rooms":
+			if param < 0 {
+				param = 16
+			}
+			return generateRoomsMap(width, height, int(param), rng)
+		case "caves":
+			if param < 0 {
+				param = 0.45
+			}
+			if param > 1 {
+				return [][]bool{}, errors.New("Fill ratio must be between 0 and 1")
+			}
+			return generateCavesMap(width, height, param, rng), nil
+		case "maze":
+			if param < 0 {
+				param = 1
+			}
+			return generateMazeMap(width, height, int(param), rng)
+	}
+	msg := fmt.Sprintf("Unknown generator \"%s\", accepted generators are \"%s\"", generator, strings.Join(mapGenerators, "\", \""))
+	return [][]bool{}, errors.New(msg)
+}
+
+func newGrid(width, height int, blocked bool) [][]bool {
+	g := make([][]bool, height)
+	for y := 0; y < height; y++ {
+		g[y] = make([]bool, width)
+		for x := 0; x < width; x++ {
+			g[y][x] = blocked
+		}
+	}
+	return g
+}
+
+/*
+ * Every cell is blocked with the given probability.
+ */
+func generateRandomMap(width, height int, density float64, rng *rand.Rand) [][]bool {
+	g := newGrid(width, height, false)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			g[y][x] = rng.Float64() < density
+		}
+	}
+	return g
+}
+
+/*
+ * Cellular automaton caves. The grid is filled randomly and then
+ * smoothed a few times: a cell becomes blocked if at least 5 of its 8
+ * neighbours are blocked and open if at most 3 are. Cells outside the
+ * grid count as blocked, which closes the caves at the border.
+ */
+func generateCavesMap(width, height int, fill float64, rng *rand.Rand) [][]bool {
+	g := generateRandomMap(width, height, fill, rng)
+	for iter := 0; iter < 5; iter++ {
+		next := newGrid(width, height, false)
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				blocked := 0
+				for dy := -1; dy <= 1; dy++ {
+					for dx := -1; dx <= 1; dx++ {
+						nx, ny := x+dx, y+dy
+						if dx == 0 && dy == 0 {
+							continue
+						}
+						if nx < 0 || nx >= width || ny < 0 || ny >= height || g[ny][nx] {
+							blocked++
+						}
+					}
+				}
+				if blocked >= 5 {
+					next[y][x] = true
+				} else if blocked <= 3 {
+					next[y][x] = false
+				} else {
+					next[y][x] = g[y][x]
+				}
+			}
+		}
+		g = next
+	}
+	return g
+}
+
+/*
+ * A perfect maze with corridors of the given width and walls that are
+ * one cell thick, like the movingai maze maps. The corridors are carved
+ * with a randomized depth-first search. Space that does not fit a whole
+ * corridor at the right and bottom edges stays blocked.
+ */
+func generateMazeMap(width, height, corridor int, rng *rand.Rand) ([][]bool, error) {
+	if corridor < 1 {
+		return [][]bool{}, errors.New("Corridor width must be positive")
+	}
+	cols := (width-1)  / (corridor+1)
+	rows := (height-1) / (corridor+1)
+	if cols < 1 || rows < 1 {
+		return [][]bool{}, errors.New("The map is too small for the corridor width")
+	}
+	g := newGrid(width, height, true)
+	carveCells(g, cols, rows, corridor)
+	for _, wall := range(spanningTreeWalls(cols, rows, rng)) {
+		carveWall(g, wall, corridor, 0, corridor)
+	}
+	return g, nil
+}
+
+/*
+ * Square rooms of the given width separated by walls that are one cell
+ * thick. Every room is reachable: doors are made along a random spanning
+ * tree of the rooms and some extra doors create loops. Doors are a
+ * quarter of the room width wide.
+ */
+func generateRoomsMap(width, height, room int, rng *rand.Rand) ([][]bool, error) {
+	if room < 1 {
+		return [][]bool{}, errors.New("Room width must be positive")
+	}
+	cols := (width-1)  / (room+1)
+	rows := (height-1) / (room+1)
+	if cols < 1 || rows < 1 {
+		return [][]bool{}, errors.New("The map is too small for the room width")
+	}
+	g := newGrid(width, height, true)
+	carveCells(g, cols, rows, room)
+
+	door := room / 4
+	if door < 1 {
+		door = 1
+	}
+	doors := spanningTreeWalls(cols, rows, rng)
+	for _, wall := range(allWalls(cols, rows)) {
+		if rng.Float64() < 0.25 {
+			doors = append(doors, wall)
+		}
+	}
+	for _, wall := range(doors) {
+		offset := rng.Intn(room - door + 1)
+		carveWall(g, wall, room, offset, door)
+	}
+	return g, nil
+}
+
+// A wall between two neighbouring cells of a maze or rooms layout.
+// The second cell is always east or south of the first one.
+type layoutWall struct {
+	X, Y       int
+	Horizontal bool // The wall is south of (X,Y), otherwise east of it
+}
+
+// Opens the interiors of all layout cells
+func carveCells(g [][]bool, cols, rows, size int) {
+	for row := 0; row < rows; row++ {
+		for col := 0; col < cols; col++ {
+			x0 := 1 + col*(size+1)
+			y0 := 1 + row*(size+1)
+			for y := y0; y < y0+size; y++ {
+				for x := x0; x < x0+size; x++ {
+					g[y][x] = false
+				}
+			}
+		}
+	}
+}
+
+// Opens length cells of a wall, starting offset cells along it
+func carveWall(g [][]bool, wall layoutWall, size, offset, length int) {
+	x0 := 1 + wall.X*(size+1)
+	y0 := 1 + wall.Y*(size+1)
+	for i := offset; i < offset+length; i++ {
+		if wall.Horizontal {
+			g[y0+size][x0+i] = false
+		} else {
+			g[y0+i][x0+size] = false
+		}
+	}
+}
+
+func allWalls(cols, rows int) []layoutWall {
+	walls := []layoutWall{}
+	for row := 0; row < rows; row++ {
+		for col := 0; col < cols; col++ {
+			if col+1 < cols {
+				walls = append(walls, layoutWall{col, row, false})
+			}
+			if row+1 < rows {
+				walls = append(walls, layoutWall{col, row, true})
+			}
+		}
+	}
+	return walls
+}
+
+/*
+ * Randomized depth-first search over the layout cells. Returns the walls
+ * that have to be removed to connect every cell exactly once.
+ */
+func spanningTreeWalls(cols, rows int, rng *rand.Rand) []layoutWall {
+	walls   := []layoutWall{}
+	visited := make([]bool, cols*rows)
+	stack   := []int{rng.Intn(cols*rows)}
+	visited[stack[0]] = true
+	for len(stack) > 0 {
+		cell := stack[len(stack)-1]
+		col, row := cell%cols, cell/cols
+
+		unvisited := []int{}
+		for _, d := range([][2]int{{0,-1}, {1,0}, {0,1}, {-1,0}}) {
+			c, r := col+d[0], row+d[1]
+			if c >= 0 && c < cols && r >= 0 && r < rows && !visited[r*cols+c] {
+				unvisited = append(unvisited, r*cols+c)
+			}
+		}
+		if len(unvisited) == 0 {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		next := unvisited[rng.Intn(len(unvisited))]
+		nextCol, nextRow := next%cols, next/cols
+		// Walls are stored relative to the north-west cell of the pair
+		wall := layoutWall{col, row, nextRow != row}
+		if nextCol < col || nextRow < row {
+			wall.X, wall.Y = nextCol, nextRow
+		}
+		walls = append(walls, wall)
+		visited[next] = true
+		stack = append(stack, next)
+	}
+	return walls
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+/*
+ * Every generator must give the same map for the same seed, a different
+ * one for another seed, and a map of the requested size.
+ */
+func TestGenerateMapSeeds(t *testing.T) {
+	for _, generator := range(mapGenerators) {
+		for _, size := range([][2]int{{40, 30}, {37, 61}}) {
+			w, h := size[0], size[1]
+			g1, err1 := GenerateMap(generator, w, h, -1, 1)
+			g2, err2 := GenerateMap(generator, w, h, -1, 1)
+			g3, err3 := GenerateMap(generator, w, h, -1, 2)
+			if err1 != nil || err2 != nil || err3 != nil {
+				t.Errorf("%s %dx%d: errors %v, %v, %v", generator, w, h, err1, err2, err3)
+				continue
+			}
+			if len(g1) != h || len(g1[0]) != w {
+				t.Errorf("%s %dx%d: the map is %dx%d", generator, w, h, len(g1[0]), len(g1))
+			}
+			if gridString(g1) != gridString(g2) {
+				t.Errorf("%s %dx%d: seed 1 gave two different maps\n%s\n%s", generator, w, h, gridString(g1), gridString(g2))
+			}
+			if gridString(g1) == gridString(g3) {
+				t.Errorf("%s %dx%d: seeds 1 and 2 gave the same map\n%s", generator, w, h, gridString(g1))
+			}
+		}
+	}
+}
+
+/*
+ * On a 200x200 map the open ratio of random maps must be close to one
+ * minus the density, and caves must get more open as the fill ratio
+ * goes down.
+ */
+func TestGenerateMapDensity(t *testing.T) {
+	openRatio := func(g [][]bool) float64 {
+		open := 0
+		for _, row := range(g) {
+			for _, blocked := range(row) {
+				if !blocked {
+					open++
+				}
+			}
+		}
+		return float64(open) / float64(len(g)*len(g[0]))
+	}
+	for _, density := range([]float64{0, 0.1, 0.25, 0.5, 1}) {
+		g, _ := GenerateMap("random", 200, 200, density, 1)
+		if ratio := openRatio(g); math.Abs(ratio - (1 - density)) > 0.01 {
+			t.Errorf("random map with density %g has open ratio %g", density, ratio)
+		}
+	}
+	lastRatio := 1.0
+	for _, fill := range([]float64{0.35, 0.45, 0.55, 1}) {
+		g, _ := GenerateMap("caves", 200, 200, fill, 1)
+		ratio := openRatio(g)
+		if ratio >= lastRatio {
+			t.Errorf("caves with fill ratio %g have open ratio %g, not less than %g", fill, ratio, lastRatio)
+		}
+		lastRatio = ratio
+	}
+	if lastRatio != 0 {
+		t.Errorf("caves with fill ratio 1 have open ratio %g", lastRatio)
+	}
+}
+
+/*
+ * Checks the layout of maze and rooms maps: every room or corridor cell
+ * is open, the walls between them are blocked except for doors of the
+ * expected width, nothing else is open and all open cells are connected.
+ * A maze is a spanning tree of its cells, so it has exactly one door
+ * fewer than cells and every door is a whole wall. An extra rooms door
+ * can land on a wall of the spanning tree, so a wall has up to two.
+ */
+func TestGenerateMapLayouts(t *testing.T) {
+	keepGlobals(t)
+	nodeModel, connectivity = CellCenters, FourConnected
+	cases := []struct {
+		generator     string
+		width, height int
+		size          int
+		door          int
+	}{
+		{"maze", 41, 31, 1, 1},
+		{"maze", 42, 33, 3, 3},
+		{"maze", 10, 10, 8, 8},
+		{"rooms", 70, 52, 16, 4},
+		{"rooms", 31, 40, 5, 1},
+		{"rooms", 25, 25, 2, 1},
+	}
+	for _, c := range(cases) {
+		for seed := int64(1); seed <= 5; seed++ {
+			g, err := GenerateMap(c.generator, c.width, c.height, float64(c.size), seed)
+			if err != nil {
+				t.Errorf("%s %dx%d, size %d: %s", c.generator, c.width, c.height, c.size, err.Error())
+				continue
+			}
+			cols := (c.width-1)  / (c.size+1)
+			rows := (c.height-1) / (c.size+1)
+			open := 0
+			for _, row := range(g) {
+				for _, blocked := range(row) {
+					if !blocked {
+						open++
+					}
+				}
+			}
+
+			expectedOpen := cols*rows*c.size*c.size
+			doors := 0
+			for _, wall := range(allWalls(cols, rows)) {
+				x0 := 1 + wall.X*(c.size+1)
+				y0 := 1 + wall.Y*(c.size+1)
+				wallOpen := 0
+				for i := 0; i < c.size; i++ {
+					if wall.Horizontal && !g[y0+c.size][x0+i] || !wall.Horizontal && !g[y0+i][x0+c.size] {
+						wallOpen++
+					}
+				}
+				maxOpen := c.door
+				if c.generator == "rooms" {
+					maxOpen = 2*c.door
+				}
+				if wallOpen != 0 && (wallOpen < c.door || wallOpen > maxOpen) {
+					t.Errorf("%s %dx%d, size %d, seed %d: wall %v has %d open cells, want 0 or %d to %d\n%s",
+						c.generator, c.width, c.height, c.size, seed, wall, wallOpen, c.door, maxOpen, gridString(g))
+				}
+				if wallOpen != 0 {
+					doors++
+				}
+				expectedOpen += wallOpen
+			}
+			if c.generator == "maze" && doors != cols*rows-1 || doors < cols*rows-1 {
+				t.Errorf("%s %dx%d, size %d, seed %d: %d doors for %d cells\n%s",
+					c.generator, c.width, c.height, c.size, seed, doors, cols*rows, gridString(g))
+			}
+			carved := newGrid(c.width, c.height, true)
+			carveCells(carved, cols, rows, c.size)
+			for y := 0; y < c.height; y++ {
+				for x := 0; x < c.width; x++ {
+					if !carved[y][x] && g[y][x] {
+						t.Errorf("%s %dx%d, size %d, seed %d: cell (%d,%d) of a room is blocked",
+							c.generator, c.width, c.height, c.size, seed, x, y)
+					}
+				}
+			}
+			if open != expectedOpen {
+				t.Errorf("%s %dx%d, size %d, seed %d: %d open cells, want %d in the rooms and doors\n%s",
+					c.generator, c.width, c.height, c.size, seed, open, expectedOpen, gridString(g))
+			}
+
+			setGrid(g)
+			if counts := componentCellCounts(); len(counts) != 1 || counts[0] != open {
+				t.Errorf("%s %dx%d, size %d, seed %d: components with %v of %d open cells\n%s",
+					c.generator, c.width, c.height, c.size, seed, counts, open, gridString(g))
+			}
+		}
+	}
+}
+
+func TestGenerateMapErrors(t *testing.T) {
+	cases := []struct {
+		generator     string
+		width, height int
+		param         float64
+	}{
+		{"forest", 10, 10, -1},
+		{"random", 0, 10, -1},
+		{"caves", 10, -3, -1},
+		{"random", 10, 10, 1.5},
+		{"caves", 10, 10, 2},
+		{"maze", 10, 10, 0},
+		{"maze", 10, 2, 1},
+		{"maze", 10, 10, 9},
+		{"rooms", 10, 10, 0.5},
+		{"rooms", 17, 18, 16},
+	}
+	for _, c := range(cases) {
+		if _, err := GenerateMap(c.generator, c.width, c.height, c.param, 1); err == nil {
+			t.Errorf("%s %dx%d with param %g: no error", c.generator, c.width, c.height, c.param)
+		}
+	}
+}
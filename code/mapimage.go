@@ -8,6 +8,7 @@ import (
 	"golang.org/x/image/draw"
 	"image/color"
 	"image/jpeg"
+	"github.com/llgcode/draw2d"
 	"github.com/llgcode/draw2d/draw2dimg"
 )
 
@@ -52,7 +53,36 @@ func SaveImage(img *image.RGBA, fname string) error {
 	return nil
 }
 
-func DrawPath(img *image.RGBA, path []Node, scale int) *image.RGBA {
+/*
+ * Shades the area that the agent sweeps along the path when it is larger
+ * than a point, see agentFootprint. The pieces are filled together so
+ * that their overlaps are not darker.
+ */
+func DrawAgentFootprint(img *image.RGBA, path []Point, scale int) *image.RGBA {
+	footprint := agentFootprint(path)
+	if len(footprint) == 0 {
+		return img
+	}
+	scaleF := float64(scale)
+
+	gc := draw2dimg.NewGraphicContext(img)
+	defer gc.Close()
+
+	gc.SetFillColor(color.NRGBA{255,165,0,110})
+	gc.SetFillRule(draw2d.FillRuleWinding)
+	gc.BeginPath()
+	for _, polygon := range(footprint) {
+		gc.MoveTo(scaleF * polygon[0].X, scaleF * polygon[0].Y)
+		for _, p := range(polygon[1:]) {
+			gc.LineTo(scaleF * p.X, scaleF * p.Y)
+		}
+		gc.Close()
+	}
+	gc.Fill()
+	return img
+}
+
+func DrawPath(img *image.RGBA, path []Point, scale int) *image.RGBA {
 	if len(path) == 0 {
 		return img
 	}
@@ -65,8 +95,8 @@ func DrawPath(img *image.RGBA, path []Node, scale int) *image.RGBA {
 	gc.SetLineWidth(lineWidth)
 	var prevX, prevY float64
 	for i, n := range(path) {
-		x := float64(n.X)
-		y := float64(n.Y)
+		x := n.X
+		y := n.Y
 		if i > 0 {
 			// Line between path nodes
 			gc.SetStrokeColor(color.RGBA{255,0,0,255})
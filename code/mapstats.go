@@ -0,0 +1,149 @@
+package main
+
+import (
+	"math"
+)
+
+type MapStats struct {
+	Width, Height    int
+	OpenCells        int
+	OpenRatio        float64
+	ComponentCount   int
+	LargestComponent int // Number of open cells in the largest component
+	AvgClearance     float64
+	AvgCorridorWidth float64
+	CornerNodes      int
+}
+
+/*
+ * Computes statistics about the current grid.
+ */
+func ComputeMapStats() MapStats {
+	s := MapStats{}
+	s.Height = len(grid)
+	s.Width  = len(grid[0])
+
+	for y := 0; y < s.Height; y++ {
+		for x := 0; x < s.Width; x++ {
+			if !grid[y][x] {
+				s.OpenCells++
+			}
+		}
+	}
+	s.OpenRatio = float64(s.OpenCells) / float64(s.Width*s.Height)
+
+	s.ComponentCount = len(componentSizes)
+	for _, count := range(componentCellCounts()) {
+		if count > s.LargestComponent {
+			s.LargestComponent = count
+		}
+	}
+
+	// The corridor width is estimated on the ridges of the clearance,
+	// ie open cells that are at least as far from walls as their
+	// neighbours. A ridge cell with clearance c lies in the middle of a
+	// corridor that is about 2c-1 cells wide.
+	clearance  := cellClearance()
+	sumClearance := 0
+	sumWidth     := 0
+	ridgeCells   := 0
+	for y := 0; y < s.Height; y++ {
+		for x := 0; x < s.Width; x++ {
+			c := clearance[y][x]
+			if c == 0 {
+				continue
+			}
+			sumClearance += c
+			ridge := true
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					nx, ny := x+dx, y+dy
+					if nx >= 0 && nx < s.Width && ny >= 0 && ny < s.Height && clearance[ny][nx] > c {
+						ridge = false
+					}
+				}
+			}
+			if ridge {
+				sumWidth += 2*c - 1
+				ridgeCells++
+			}
+		}
+	}
+	if s.OpenCells > 0 {
+		s.AvgClearance = float64(sumClearance) / float64(s.OpenCells)
+	}
+	if ridgeCells > 0 {
+		s.AvgCorridorWidth = float64(sumWidth) / float64(ridgeCells)
+	}
+
+	for y := 0; y <= s.Height; y++ {
+		for x := 0; x <= s.Width; x++ {
+			if isCornerNode(x, y) {
+				s.CornerNodes++
+			}
+		}
+	}
+	return s
+}
+
+/*
+ * Brushfire distance of every cell to the closest blocked cell, counted
+ * in 8-connected steps. Blocked cells have clearance 0, open cells next
+ * to a blocked cell have clearance 1 and so on. Cells outside the map
+ * count as blocked.
+ */
+func cellClearance() [][]int {
+	h := len(grid)
+	w := len(grid[0])
+	clearance := make([][]int, h)
+	queue := []Node{}
+	for y := 0; y < h; y++ {
+		clearance[y] = make([]int, w)
+		for x := 0; x < w; x++ {
+			if grid[y][x] {
+				clearance[y][x] = 0
+				queue = append(queue, NewNode(x, y))
+			} else if x == 0 || y == 0 || x == w-1 || y == h-1 {
+				clearance[y][x] = 1
+				queue = append(queue, NewNode(x, y))
+			} else {
+				clearance[y][x] = math.MaxInt32
+			}
+		}
+	}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for dy := -1; dy <= 1; dy++ {
+			for dx := -1; dx <= 1; dx++ {
+				x, y := n.X+dx, n.Y+dy
+				if x >= 0 && x < w && y >= 0 && y < h && clearance[y][x] > clearance[n.Y][n.X]+1 {
+					clearance[y][x] = clearance[n.Y][n.X]+1
+					queue = append(queue, NewNode(x, y))
+				}
+			}
+		}
+	}
+	return clearance
+}
+
+/*
+ * A corner node touches the corner of a blocked cell without lying
+ * along a wall: exactly one of its four cells is blocked, or two
+ * diagonally opposite cells are. Shortest any-angle paths only turn at
+ * corner nodes.
+ */
+func isCornerNode(x, y int) bool {
+	nwOpen := isOpen(x-1, y-1)
+	neOpen := isOpen(x,   y-1)
+	seOpen := isOpen(x,   y)
+	swOpen := isOpen(x-1, y)
+	blocked := 0
+	for _, open := range([]bool{nwOpen, neOpen, seOpen, swOpen}) {
+		if !open {
+			blocked++
+		}
+	}
+	return blocked == 1 ||
+	       (blocked == 2 && nwOpen == seOpen)
+}
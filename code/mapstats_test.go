@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+/*
+ * Computes the statistics of small maps worked out by hand. The
+ * clearance rows give the expected clearance of every cell. Components
+ * are counted in the cell-center model with 8-connectivity.
+ */
+func TestComputeMapStats(t *testing.T) {
+	keepGlobals(t)
+	nodeModel, connectivity, agentSize = CellCenters, EightConnected, 0
+	const epsilon = 1e-9
+	cases := []struct {
+		rows             []string
+		clearance        []string
+		openRatio        float64
+		components       int
+		largest          int
+		avgClearance     float64
+		avgCorridorWidth float64
+		cornerNodes      int
+	}{
+		// An open room has one ridge cell in the middle and no corner
+		// nodes, since the border is a wall
+		{
+			[]string{".....", ".....", ".....", ".....", "....."},
+			[]string{"11111", "12221", "12321", "12221", "11111"},
+			1, 1, 25, 35.0/25, 5, 0,
+		},
+		// The middle row of a corridor 3 cells wide is the ridge
+		{
+			[]string{".......", ".......", "......."},
+			[]string{"1111111", "1222221", "1111111"},
+			1, 1, 21, 26.0/21, 3, 0,
+		},
+		// The block in the middle has 4 corner nodes and the wall at the
+		// bottom only 1. The ridge has 8 cells of width 1 and (3,1) of
+		// width 3.
+		{
+			[]string{".....", ".@...", ".....", "...@@"},
+			[]string{"11111", "10121", "11111", "11100"},
+			17.0/20, 1, 17, 18.0/17, 11.0/9, 5,
+		},
+		// Diagonal blocks share a corner node
+		{
+			[]string{"....", ".@..", "..@.", "...."},
+			[]string{"1111", "1011", "1101", "1111"},
+			14.0/16, 1, 14, 1, 1, 7,
+		},
+		// A wall splits the map in two rooms
+		{
+			[]string{"..@...", "..@..."},
+			[]string{"110111", "110111"},
+			10.0/12, 2, 6, 1, 1, 0,
+		},
+	}
+	for i, c := range(cases) {
+		setGrid(gridFromRows(c.rows...))
+		clearance := cellClearance()
+		for y, row := range(c.clearance) {
+			if got := fmt.Sprint(clearance[y]); got != fmt.Sprint(digits(row)) {
+				t.Errorf("map %d: clearance of row %d is %s, want %v\n%s", i, y, got, digits(row), gridString(grid))
+			}
+		}
+		s := ComputeMapStats()
+		if s.Width != len(c.rows[0]) || s.Height != len(c.rows) ||
+		   math.Abs(s.OpenRatio - c.openRatio) > epsilon ||
+		   s.ComponentCount != c.components || s.LargestComponent != c.largest ||
+		   math.Abs(s.AvgClearance - c.avgClearance) > epsilon ||
+		   math.Abs(s.AvgCorridorWidth - c.avgCorridorWidth) > epsilon ||
+		   s.CornerNodes != c.cornerNodes {
+			t.Errorf("map %d: stats are %+v, want open ratio %g, %d components, largest %d, clearance %g, corridor width %g, %d corner nodes\n%s",
+				i, s, c.openRatio, c.components, c.largest, c.avgClearance, c.avgCorridorWidth, c.cornerNodes, gridString(grid))
+		}
+	}
+}
+
+/*
+ * Every combination of open and blocked cells around a node. Only one
+ * blocked cell or two diagonally opposite ones make a corner node.
+ */
+func TestIsCornerNode(t *testing.T) {
+	keepGlobals(t)
+	nodeModel, connectivity, agentSize = CellCenters, EightConnected, 0
+	for mask := 0; mask < 16; mask++ {
+		// Bits 0 to 3 block the nw, ne, se and sw cells of node (2,2)
+		g := newGrid(4, 4, false)
+		g[1][1] = mask&1 != 0
+		g[1][2] = mask&2 != 0
+		g[2][2] = mask&4 != 0
+		g[2][1] = mask&8 != 0
+		setGrid(g)
+		expected := mask == 1 || mask == 2 || mask == 4 || mask == 8 || mask == 5 || mask == 10
+		if got := isCornerNode(2, 2); got != expected {
+			t.Errorf("node (2,2) is a corner node: %t, want %t\n%s", got, expected, gridString(g))
+		}
+	}
+}
+
+// The digits of a string as numbers
+func digits(s string) []int {
+	d := []int{}
+	for _, r := range(s) {
+		d = append(d, int(r - '0'))
+	}
+	return d
+}
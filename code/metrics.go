@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+/*
+ * The shape of a path and how close it gets to blocked cells. The turn
+ * count and average angle of testOneScenario skip turns below 0.001 rad
+ * and average away the sharp ones, so these are kept separately.
+ */
+type PathMetrics struct {
+	HeadingChange float64 // Sum of all turns (rad)
+	MaxTurn       float64 // Sharpest single turn (rad)
+	// Percentiles of the curvature at the turns, the angle divided by the
+	// average length of the two pieces (rad per cell)
+	CurvatureP50, CurvatureP90, CurvatureMax float64
+	MinClearance  float64 // Smallest distance from the path to a blocked cell or the map border (cells)
+	NearWalls     float64 // Fraction of the length closer than nearWallDistance to a blocked cell
+}
+
+// Paths along a wall in the corner model touch it, and paths between
+// cell centers next to a wall are half a cell away
+const nearWallDistance = 0.5
+
+// Step for sampling the length near walls (cells)
+const nearWallStep = 0.05
+
+func ComputePathMetrics(path []Point) PathMetrics {
+	m := PathMetrics{}
+	if len(path) == 0 {
+		return m
+	}
+
+	curvatures := []float64{}
+	for i := 0; i < len(path)-2; i++ {
+		l1 := PointDist(path[i], path[i+1])
+		l2 := PointDist(path[i+1], path[i+2])
+		if l1 == 0 || l2 == 0 {
+			continue
+		}
+		a := turnAngle(path[i], path[i+1], path[i+2])
+		m.HeadingChange += a
+		m.MaxTurn = math.Max(m.MaxTurn, a)
+		if a >= 0.001 {
+			curvatures = append(curvatures, a / ((l1 + l2) / 2))
+		}
+	}
+	if len(curvatures) > 0 {
+		sort.Float64s(curvatures)
+		percentile := func(p float64) float64 {
+			return curvatures[int(math.Ceil(p * float64(len(curvatures)))) - 1]
+		}
+		m.CurvatureP50 = percentile(0.5)
+		m.CurvatureP90 = percentile(0.9)
+		m.CurvatureMax = curvatures[len(curvatures)-1]
+	}
+
+	m.MinClearance = pointClearance(path[0])
+	for i := 0; i < len(path)-1; i++ {
+		m.MinClearance = math.Min(m.MinClearance, segmentClearance(path[i], path[i+1], m.MinClearance))
+	}
+
+	length := PointPathLength(path)
+	if length > 0 {
+		near := 0.0
+		for i := 0; i < len(path)-1; i++ {
+			a, b  := path[i], path[i+1]
+			d     := PointDist(a, b)
+			steps := int(math.Ceil(d / nearWallStep))
+			for k := 0; k < steps; k++ {
+				// The middle of each step stands for the whole step
+				t := (float64(k) + 0.5) / float64(steps)
+				p := Point{a.X + t*(b.X - a.X), a.Y + t*(b.Y - a.Y)}
+				if pointClearanceWithin(p, nearWallDistance) <= nearWallDistance {
+					near += d / float64(steps)
+				}
+			}
+		}
+		m.NearWalls = near / length
+	}
+	return m
+}
+
+func (m PathMetrics) String() string {
+	return fmt.Sprintf("heading change %.2f rad, max turn %.2f rad (%.1f deg), curvature p50 %.3f p90 %.3f max %.3f rad/cell, min clearance %.2f, near walls %.1f%%",
+		m.HeadingChange, m.MaxTurn, m.MaxTurn*radToDeg, m.CurvatureP50, m.CurvatureP90, m.CurvatureMax, m.MinClearance, 100*m.NearWalls)
+}
+
+// The averages of the metrics of several paths
+func averagePathMetrics(all []PathMetrics) PathMetrics {
+	avg := PathMetrics{}
+	for _, m := range(all) {
+		avg.HeadingChange += m.HeadingChange / float64(len(all))
+		avg.MaxTurn       += m.MaxTurn       / float64(len(all))
+		avg.CurvatureP50  += m.CurvatureP50  / float64(len(all))
+		avg.CurvatureP90  += m.CurvatureP90  / float64(len(all))
+		avg.CurvatureMax  += m.CurvatureMax  / float64(len(all))
+		avg.MinClearance  += m.MinClearance  / float64(len(all))
+		avg.NearWalls     += m.NearWalls     / float64(len(all))
+	}
+	return avg
+}
+
+// The angle between the pieces a->b and b->c (rad)
+func turnAngle(a, b, c Point) float64 {
+	v1x, v1y := b.X - a.X, b.Y - a.Y
+	v2x, v2y := c.X - b.X, c.Y - b.Y
+	cos := (v1x*v2x + v1y*v2y) / (math.Hypot(v1x, v1y) * math.Hypot(v2x, v2y))
+	return math.Acos(math.Max(-1, math.Min(1, cos))) // Rounding errors may leave [-1,1]
+}
+
+// Distance from a point to the square of cell (x,y)
+func pointCellDist(p Point, x, y int) float64 {
+	dx := math.Max(0, math.Max(float64(x) - p.X, p.X - float64(x+1)))
+	dy := math.Max(0, math.Max(float64(y) - p.Y, p.Y - float64(y+1)))
+	return math.Hypot(dx, dy)
+}
+
+// Distance from a point to the nearest blocked cell, where the cells
+// outside the map count as blocked
+func pointClearance(p Point) float64 {
+	return pointClearanceWithin(p, math.Inf(1))
+}
+
+/*
+ * Like pointClearance, but stops looking further than limit, and then
+ * returns a distance above it. The cells are searched in growing square
+ * rings around the cell of the point until a ring is further than the
+ * nearest blocked cell found.
+ */
+func pointClearanceWithin(p Point, limit float64) float64 {
+	cx, cy := int(math.Floor(p.X)), int(math.Floor(p.Y))
+	best := math.Inf(1)
+	for r := 0; float64(r) - 1 <= math.Min(best, limit); r++ {
+		for y := cy - r; y <= cy + r; y++ {
+			for x := cx - r; x <= cx + r; x++ {
+				if (y == cy - r || y == cy + r || x == cx - r || x == cx + r) && !isCellOpen(x, y) {
+					best = math.Min(best, pointCellDist(p, x, y))
+				}
+			}
+		}
+	}
+	return best
+}
+
+/*
+ * Distance from the line a->b to the nearest blocked cell, or a distance
+ * that is not smaller than bound if no blocked cell is closer than bound.
+ * Only the cells around the line within the bound are looked at. The
+ * distance between a line and a square that it does not enter is reached
+ * at an end of the line or a corner of the square.
+ */
+func segmentClearance(a, b Point, bound float64) float64 {
+	bound = math.Min(bound, pointClearanceWithin(a, bound))
+	best  := bound
+	r := math.Ceil(bound)
+	for y := int(math.Floor(math.Min(a.Y, b.Y) - r)); float64(y) <= math.Max(a.Y, b.Y) + r; y++ {
+		for x := int(math.Floor(math.Min(a.X, b.X) - r)); float64(x) <= math.Max(a.X, b.X) + r; x++ {
+			if isCellOpen(x, y) {
+				continue
+			}
+			if segmentEntersSquare(a, b, x, y) {
+				return 0
+			}
+			d := math.Min(pointCellDist(a, x, y), pointCellDist(b, x, y))
+			for _, corner := range([][2]int{{0,0}, {1,0}, {0,1}, {1,1}}) {
+				d = math.Min(d, pointSegmentDist(Point{float64(x + corner[0]), float64(y + corner[1])}, a, b))
+			}
+			best = math.Min(best, d)
+		}
+	}
+	return best
+}
+
+// Like segmentEntersCell, in floating point
+func segmentEntersSquare(a, b Point, x, y int) bool {
+	return segmentEntersRect(a, b, Point{float64(x), float64(y)}, Point{float64(x+1), float64(y+1)})
+}
+
+// Whether the line a->b enters the interior of the rectangle between the
+// corners low and high
+func segmentEntersRect(a, b, low, high Point) bool {
+	lo, hi := 0.0, 1.0
+	for _, axis := range([][4]float64{{a.X, b.X, low.X, high.X}, {a.Y, b.Y, low.Y, high.Y}}) {
+		from, to, low, high := axis[0], axis[1], axis[2], axis[3]
+		d := to - from
+		if d == 0 {
+			if from <= low || from >= high {
+				return false
+			}
+			continue
+		}
+		t1, t2 := (low - from) / d, (high - from) / d
+		if t1 > t2 {
+			t1, t2 = t2, t1
+		}
+		lo, hi = math.Max(lo, t1), math.Min(hi, t2)
+	}
+	return lo < hi
+}
+
+func pointSegmentDist(p, a, b Point) float64 {
+	dx, dy := b.X - a.X, b.Y - a.Y
+	if dx == 0 && dy == 0 {
+		return PointDist(p, a)
+	}
+	t := ((p.X - a.X)*dx + (p.Y - a.Y)*dy) / (dx*dx + dy*dy)
+	t  = math.Max(0, math.Min(1, t))
+	return PointDist(p, Point{a.X + t*dx, a.Y + t*dy})
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// The turns of an L-shaped path on an open map
+func TestPathMetricsTurns(t *testing.T) {
+	keepGlobals(t)
+	setGrid(newGrid(8, 8, false))
+	const epsilon = 1e-9
+	m := ComputePathMetrics([]Point{{1, 1}, {4, 1}, {4, 3}})
+	if math.Abs(m.HeadingChange - math.Pi/2) > epsilon || math.Abs(m.MaxTurn - math.Pi/2) > epsilon {
+		t.Errorf("heading change %f and max turn %f, expected %f", m.HeadingChange, m.MaxTurn, math.Pi/2)
+	}
+	curvature := (math.Pi/2) / 2.5
+	if math.Abs(m.CurvatureP50 - curvature) > epsilon || math.Abs(m.CurvatureMax - curvature) > epsilon {
+		t.Errorf("curvature p50 %f and max %f, expected %f", m.CurvatureP50, m.CurvatureMax, curvature)
+	}
+	if math.Abs(m.MinClearance - 1) > epsilon || m.NearWalls != 0 {
+		t.Errorf("min clearance %f and near walls %f, expected 1 and 0", m.MinClearance, m.NearWalls)
+	}
+}
+
+/*
+ * Compares the clearance of random lines on random maps of up to 12x12
+ * cells with the distances to every blocked cell and the cells around the
+ * map, at points sampled along the lines. The sampled minimum can only
+ * miss the nearest point by half a sample step.
+ */
+func TestPathMetricsClearance(t *testing.T) {
+	keepGlobals(t)
+	const step = 0.001
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		setGrid(randomGrid(random, 12, 12, 0.3))
+		w, h := len(grid[0]), len(grid)
+		clearance := func(p Point) float64 {
+			best := math.Inf(1)
+			for y := -1; y <= h; y++ {
+				for x := -1; x <= w; x++ {
+					if !isCellOpen(x, y) {
+						best = math.Min(best, pointCellDist(p, x, y))
+					}
+				}
+			}
+			return best
+		}
+		a := Point{random.Float64() * float64(w), random.Float64() * float64(h)}
+		b := Point{random.Float64() * float64(w), random.Float64() * float64(h)}
+		sampled := math.Inf(1)
+		steps   := int(math.Ceil(PointDist(a, b) / step))
+		for k := 0; k <= steps; k++ {
+			s := float64(k) / float64(steps)
+			sampled = math.Min(sampled, clearance(Point{a.X + s*(b.X - a.X), a.Y + s*(b.Y - a.Y)}))
+		}
+		got := ComputePathMetrics([]Point{a, b}).MinClearance
+		if got > sampled + 1e-9 || got < sampled - step {
+			t.Errorf("map %d: line (%f,%f) -> (%f,%f) has clearance %f, sampled %f\n%s",
+				i, a.X, a.Y, b.X, b.Y, got, sampled, gridString(grid))
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+/*
+ * Morphology transforms that clean up a map before the searches. Each
+ * returns a new grid and leaves the given one as it is. The distances
+ * are in cells along both axes, so r = 1 reaches the 8 neighbours of a
+ * cell, like the clearance of the stats mode. Cells outside the map
+ * count as neither open nor blocked.
+ */
+
+var morphologyNames = []string{"inflate", "erode", "close", "remove-small"}
+
+// The steps that the benchmarking modes apply to their maps
+var morphologySteps = []TransformStep{}
+
+/*
+ * Reads a comma-separated chain of steps written as name:parameter, e.g.
+ * "close:1,remove-small:20,inflate:2".
+ */
+func MustParseMorphology(value string) []TransformStep {
+	return mustParseSteps(value, morphologyNames, "morphology")
+}
+
+// Applies the steps in order
+func ApplyMorphology(g [][]bool, steps []TransformStep) [][]bool {
+	for _, step := range(steps) {
+		switch step.Name {
+			case "inflate":
+				g = InflateObstacles(g, step.Params[0])
+			case "erode":
+				g = ErodeObstacles(g, step.Params[0])
+			case "close":
+				g = CloseGaps(g, step.Params[0])
+			case "remove-small":
+				g = RemoveSmallComponents(g, step.Params[0])
+			default:
+				panic("Assertion failed: unexpected morphology step")
+		}
+	}
+	return g
+}
+
+// Blocks every cell within r cells of a blocked cell
+func InflateObstacles(g [][]bool, r int) [][]bool {
+	return spread(g, r, true)
+}
+
+// Opens every cell within r cells of an open cell, so obstacles lose r
+// cells on every side and obstacles thinner than 2r+1 cells disappear
+func ErodeObstacles(g [][]bool, r int) [][]bool {
+	return spread(g, r, false)
+}
+
+/*
+ * Inflates the obstacles by r cells and erodes them again, which closes
+ * the gaps of up to 2r cells between obstacles and leaves the rest of
+ * the obstacles as they were.
+ */
+func CloseGaps(g [][]bool, r int) [][]bool {
+	return ErodeObstacles(InflateObstacles(g, r), r)
+}
+
+/*
+ * Blocks the small pockets of open space: the groups of fewer than n open
+ * cells that are not connected to other open cells. Open cells are
+ * connected when they share an edge or a corner, so the searches cannot
+ * leave a removed group in any node model or connectivity.
+ */
+func RemoveSmallComponents(g [][]bool, n int) [][]bool {
+	h := len(g)
+	w := len(g[0])
+	result  := copyGrid(g)
+	visited := newGrid(w, h, false)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if g[y][x] || visited[y][x] {
+				continue
+			}
+			// Breadth-first search over the group
+			group := []Node{NewNode(x, y)}
+			visited[y][x] = true
+			for i := 0; i < len(group); i++ {
+				c := group[i]
+				for dy := -1; dy <= 1; dy++ {
+					for dx := -1; dx <= 1; dx++ {
+						nx, ny := c.X+dx, c.Y+dy
+						if nx >= 0 && nx < w && ny >= 0 && ny < h && !g[ny][nx] && !visited[ny][nx] {
+							visited[ny][nx] = true
+							group = append(group, NewNode(nx, ny))
+						}
+					}
+				}
+			}
+			if len(group) < n {
+				for _, c := range(group) {
+					result[c.Y][c.X] = true
+				}
+			}
+		}
+	}
+	return result
+}
+
+/*
+ * Sets every cell within r cells of a cell with the given value to that
+ * value. The square around a cell is the row around it followed by the
+ * column, so the rows are searched first and then the columns.
+ */
+func spread(g [][]bool, r int, value bool) [][]bool {
+	h := len(g)
+	w := len(g[0])
+	near := make([][]bool, h) // Whether the row has the value within r cells
+	for y := 0; y < h; y++ {
+		line := make([]bool, w)
+		for x := 0; x < w; x++ {
+			line[x] = g[y][x] == value
+		}
+		near[y] = anyWithin(line, r)
+	}
+	result := newGrid(w, h, !value)
+	for x := 0; x < w; x++ {
+		line := make([]bool, h)
+		for y := 0; y < h; y++ {
+			line[y] = near[y][x]
+		}
+		for y, found := range(anyWithin(line, r)) {
+			if found {
+				result[y][x] = value
+			}
+		}
+	}
+	return result
+}
+
+// Whether each element has a true element within r elements, counted
+// with prefix sums
+func anyWithin(line []bool, r int) []bool {
+	sums := make([]int, len(line)+1)
+	for i, v := range(line) {
+		sums[i+1] = sums[i]
+		if v {
+			sums[i+1]++
+		}
+	}
+	result := make([]bool, len(line))
+	for i := range(line) {
+		lo := i - r
+		if lo < 0 {
+			lo = 0
+		}
+		hi := i + r + 1
+		if hi > len(line) {
+			hi = len(line)
+		}
+		result[i] = sums[hi] - sums[lo] > 0
+	}
+	return result
+}
+
+func copyGrid(g [][]bool) [][]bool {
+	c := make([][]bool, len(g))
+	for y, row := range(g) {
+		c[y] = append([]bool{}, row...)
+	}
+	return c
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+/*
+ * Compares inflating and eroding with their definitions on random maps of
+ * up to 16x16 cells, cell by cell, and checks that closing gaps keeps
+ * every blocked cell.
+ */
+func TestMorphology(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 300; i++ {
+		g := randomGrid(random, 16, 16, 1)
+		w := len(g[0])
+		h := len(g)
+		// Whether a cell with the value is within r cells of (x,y)
+		near := func(x, y, r int, value bool) bool {
+			for ny := y - r; ny <= y + r; ny++ {
+				for nx := x - r; nx <= x + r; nx++ {
+					if nx >= 0 && nx < w && ny >= 0 && ny < h && g[ny][nx] == value {
+						return true
+					}
+				}
+			}
+			return false
+		}
+		for r := 1; r <= 3; r++ {
+			inflated := InflateObstacles(g, r)
+			eroded   := ErodeObstacles(g, r)
+			closed   := CloseGaps(g, r)
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					if inflated[y][x] != near(x, y, r, true) || eroded[y][x] == near(x, y, r, false) || (g[y][x] && !closed[y][x]) {
+						t.Errorf("map %d, r = %d: cell (%d,%d) is %t inflated, %t eroded and %t closed\n%s",
+							i, r, x, y, inflated[y][x], eroded[y][x], closed[y][x], gridString(g))
+					}
+				}
+			}
+		}
+	}
+}
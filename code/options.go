@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Options are given as --name=value anywhere on the command line. They
+// are removed from os.Args before the mode reads its arguments.
+var options = map[string]string{}
+
+var optionDescriptions = [][2]string{
+	{"connectivity", "Movement model: \"8\" (default), \"8-no-corner-cutting\" or \"4\". With \"8\" a diagonal move between grid corners needs the cell that it crosses open, and between cell centers one open side cell"},
+	{"model", "Node model: \"corner\" (default, nodes are cell corners) or \"center\" (nodes are cell centers)"},
+	{"weight", "Heuristic weight of astar, at least 1 (default 1). Also the initial weight of arastar if larger than 3"},
+	{"budget", "Time budget of arastar in milliseconds (default 1000)"},
+	{"cluster-size", "Cluster width and height of hpastar in nodes (default 16)"},
+	{"hpa-cache", "Directory where hpastar saves its graphs and loads them in later runs on the same map (default: no cache)"},
+	{"landmarks", "Landmarks file for alt, made with the landmarks mode (default: select the landmarks before the first scenario)"},
+	{"landmark-count", "How many landmarks alt uses, the first ones of a landmarks file (default 8)"},
+	{"heuristic", "Heuristic of astar, astar-ps, thetastar, arastar, biastar, idastar, fringe, dstarlite, hpastar and subgoal: \"octile\", \"euclidean\", \"manhattan\", \"chebyshev\", \"zero\" or \"landmark\" (default: octile, manhattan with 4-connectivity, euclidean for thetastar)"},
+	{"tie-breaking", "How dijkstra, astar, astar-ps, thetastar, arastar, bidijkstra, biastar and alt choose between nodes with the same f score: \"lifo\" (default), \"fifo\", \"higher-g\", \"lower-h\" or \"random\""},
+	{"tie-breaking-seed", "Seed of the random tie-breaking (default 1)"},
+	{"smooth", "Comma-separated post-processing steps for the paths of any algorithm, in order: \"greedy\" (one pass of the smoothing of astar-ps), \"greedy-repeat\" (until nothing changes), \"string-pull\" (shortest path in the cells that the path touches) and \"catmull-rom\" (curves where they are clear) (default: none)"},
+	{"agent-size", "Width of the agents in cells, which occupy a square with their node as its top-left corner (corner model) or top-left cell (center model) and only move where the whole square fits (default 0: points, or single cells in the center model)"},
+	{"morphology", "Comma-separated transforms that single, multiple, compare, tie-breaking, landmarks and transform apply to the map after loading it, in order: \"inflate:r\" (block the cells within r cells of a blocked cell), \"erode:r\" (open the cells within r cells of an open cell), \"close:r\" (inflate and erode, which closes gaps of up to 2r cells) and \"remove-small:n\" (block groups of fewer than n open cells) (default: none)"},
+	{"costs", "Cell costs file for fielddstar, with one line of positive costs per row of the map (default: every open cell costs 1)"},
+}
+
+func extractOptions() {
+	args := []string{}
+	for _, arg := range(os.Args) {
+		if !strings.HasPrefix(arg, "--") {
+			args = append(args, arg)
+			continue
+		}
+		splits := strings.SplitN(arg[2:], "=", 2)
+		if len(splits) != 2 || !isKnownOption(splits[0]) {
+			fmt.Printf("Unknown option \"%s\". Run %s without parameters for more info.\n", arg, os.Args[0])
+			os.Exit(1)
+		}
+		options[splits[0]] = splits[1]
+	}
+	os.Args = args
+}
+
+func isKnownOption(name string) bool {
+	for _, option := range(optionDescriptions) {
+		if option[0] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Sets the global state that the options control
+func applyOptions() {
+	if value, found := options["connectivity"]; found {
+		connectivity = MustParseConnectivity(value)
+	}
+	if value, found := options["model"]; found {
+		nodeModel = MustParseNodeModel(value)
+	}
+	if value, found := options["weight"]; found {
+		heuristicWeight = MustParseFloat(value)
+		if heuristicWeight < 1 {
+			fmt.Println("The heuristic weight must be at least 1.")
+			os.Exit(1)
+		}
+	}
+	if value, found := options["budget"]; found {
+		budget := MustParseInt(value)
+		if budget < 1 {
+			fmt.Println("The time budget must be at least 1ms.")
+			os.Exit(1)
+		}
+		araBudget = time.Duration(budget) * time.Millisecond
+	}
+	if value, found := options["cluster-size"]; found {
+		hpaClusterSize = MustParseInt(value)
+		if hpaClusterSize < 2 {
+			fmt.Println("The cluster size must be at least 2.")
+			os.Exit(1)
+		}
+	}
+	if value, found := options["hpa-cache"]; found {
+		hpaCacheDir = value
+	}
+	if value, found := options["landmarks"]; found {
+		landmarkPath = value
+	}
+	if value, found := options["landmark-count"]; found {
+		landmarkCount = MustParseInt(value)
+		if landmarkCount < 1 {
+			fmt.Println("The landmark count must be at least 1.")
+			os.Exit(1)
+		}
+	}
+	if value, found := options["heuristic"]; found {
+		selectedHeuristic = MustParseHeuristic(value)
+	}
+	if value, found := options["tie-breaking"]; found {
+		tieBreaking = MustParseTieBreaking(value)
+	}
+	if value, found := options["tie-breaking-seed"]; found {
+		tieBreakingSeed = int64(MustParseInt(value))
+	}
+	if value, found := options["smooth"]; found {
+		smoothingSteps = MustParseSmoothing(value)
+	}
+	if value, found := options["agent-size"]; found {
+		agentSize = MustParseAgentSize(value)
+	}
+	if value, found := options["morphology"]; found {
+		morphologySteps = MustParseMorphology(value)
+	}
+	if value, found := options["costs"]; found {
+		costs, err := LoadCosts(value)
+		if err != nil {
+			fmt.Printf("Error reading costs file \"%s\": %s\n", value, err.Error())
+			os.Exit(1)
+		}
+		cellCosts = costs
+	}
+}
+
+func printOptionsHelp() {
+	fmt.Println("Options are given as --name=value:")
+	for _, option := range(optionDescriptions) {
+		fmt.Printf("    --%s: %s\n", option[0], option[1])
+	}
+}
+
+func MustParseConnectivity(value string) Connectivity {
+	switch strings.ToLower(value) {
+		case "8":
+			return EightConnected
+		case "8-no-corner-cutting":
+			return EightConnectedNoCornerCutting
+		case "4":
+			return FourConnected
+	}
+	fmt.Printf("Unknown connectivity \"%s\"\n", value)
+	os.Exit(1)
+	return EightConnected
+}
+
+func MustParseNodeModel(value string) NodeModel {
+	switch strings.ToLower(value) {
+		case "corner":
+			return GridCorners
+		case "center":
+			return CellCenters
+	}
+	fmt.Printf("Unknown node model \"%s\"\n", value)
+	os.Exit(1)
+	return GridCorners
+}
+
+// The names that MustParseConnectivity and MustParseNodeModel read
+func connectivityName(c Connectivity) string {
+	return [...]string{"8", "8-no-corner-cutting", "4"}[c]
+}
+
+func nodeModelName(m NodeModel) string {
+	return [...]string{"corner", "center"}[m]
+}
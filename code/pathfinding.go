@@ -16,29 +16,45 @@ var g          map[Node]float64
 var f          map[Node]float64
 var parent     map[Node]Node
 var heuristic  func(Node, Node) float64
+var heuristicWeight = 1.0 // Weighted A* finds paths at most this many times longer than optimal
 var timestamp  map[Node]int // Stores when a node had its f score updated last
 var timestampCounter int
 
+// The largest number of entries that the last search kept in its data
+// structures at the same time, a measure of its memory footprint
+var peakStoredNodes int
+
+func noteStoredNodes(n int) {
+	peakStoredNodes = int(math.Max(float64(peakStoredNodes), float64(n)))
+}
+
 func resetPathfindingStructures() {
 	open      = map[Node]bool{}
 	closed    = map[Node]bool{}
 	parent    = map[Node]Node{}
-	timestamp = map[Node]int{} // Default value 0
-	timestampCounter = 0
+	timestamp = map[Node]int{} // Default value 0, which only the start keeps
+	timestampCounter = 1
+	expansions = 0
 
 	heuristic = func(Node, Node) float64 {
 		panic("Non-initialized heuristic function")
 	}
 
+	// The scores start out empty instead of infinite for every node, see
+	// gScore. Then the memory of a search and the stored nodes that the
+	// modes report grow with the nodes that it reaches, not with the map,
+	// and A* can be compared with the low-memory searches.
 	g = map[Node]float64{}
 	f = map[Node]float64{}
-	for y := 0; y <= len(grid); y++ {
-		for x := 0; x <= len(grid[0]); x++ {
-			node := NewNode(x, y)
-			g[node] = math.Inf(1)
-			f[node] = math.Inf(1)
-		}
+}
+
+// Missing g scores are infinite, so that only the nodes that a search
+// reaches take memory
+func gScore(n Node) float64 {
+	if score, found := g[n]; found {
+		return score
 	}
+	return math.Inf(1)
 }
 
 func timestampNode(n Node) {
@@ -46,6 +62,36 @@ func timestampNode(n Node) {
 	timestampCounter++
 }
 
+/*
+ * A node in a nodeQueue with its cost from the start of the search when
+ * it was queued. The priority is that cost plus the heuristic, or just
+ * the cost in Dijkstra's algorithm. Nodes with the same priority come out
+ * by the lowest cost.
+ */
+type queuedNode struct {
+	node     Node
+	priority float64
+	g        float64
+}
+
+// A min-heap of nodes for container/heap, for the searches that do not
+// need the open set or the timestamps of A*
+type nodeQueue []queuedNode
+
+func (q nodeQueue) Len() int            { return len(q) }
+func (q nodeQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *nodeQueue) Push(x interface{}) { *q = append(*q, x.(queuedNode)) }
+func (q *nodeQueue) Pop() interface{} {
+	old   := *q
+	entry := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return entry
+}
+
+func (q nodeQueue) Less(i, j int) bool {
+	return q[i].priority < q[j].priority || (q[i].priority == q[j].priority && q[i].g < q[j].g)
+}
+
 // This function assumes that the nodes are neighbours
 func costToNeighbour(n1, n2 Node) float64 {
 	if n1.X != n2.X && n1.Y != n2.Y {
@@ -56,16 +102,31 @@ func costToNeighbour(n1, n2 Node) float64 {
 }
 
 func AStar(start, goal Node) []Node {
+	if !sameComponent(start, goal) {
+		return []Node{}
+	}
 	resetPathfindingStructures()
+	h := searchHeuristic(gridHeuristic)
 	heuristic = func(from, to Node) float64 {
-		dx := math.Abs(float64(from.X - to.X))
-		dy := math.Abs(float64(from.Y - to.Y))
-		return dx + dy + (SQRT2 - 2) * math.Min(dx, dy)
+		return heuristicWeight * h(from, to)
 	}
 	return findPath(start, goal)
 }
 
+// Exact distance on an empty grid with the current connectivity
+func gridHeuristic(from, to Node) float64 {
+	dx := math.Abs(float64(from.X - to.X))
+	dy := math.Abs(float64(from.Y - to.Y))
+	if connectivity == FourConnected {
+		return dx + dy // Manhattan
+	}
+	return dx + dy + (SQRT2 - 2) * math.Min(dx, dy) // Octile
+}
+
 func Dijkstra(start, goal Node) []Node {
+	if !sameComponent(start, goal) {
+		return []Node{}
+	}
 	resetPathfindingStructures()
 	heuristic = func(current Node, goal Node) float64 {
 		return 0
@@ -101,6 +162,8 @@ func reconstructPath(start, goal Node) []Node {
 }
 
 func openNodeWithLowestF() Node {
+	noteStoredNodes(len(open) + len(closed) + len(g) + len(f) + len(parent) + len(timestamp))
+	expansions++
 	var lowestNode Node
 	firstIter := true // used to initialize lowestNode
 	for node, _ := range(open) {
@@ -117,15 +180,10 @@ func openNodeWithLowestF() Node {
 
 		if fScore < f[lowestNode] {
 			lowestNode = node
-		} else if fScore == f[lowestNode] {
-			if timestamp[lowestNode] == timestamp[node] {
-				panic("Assertion error: unexpected equal timestamp")
-			}
-			// Break this tie by choosing the node
-			// that was added last (LIFO)
-			if timestamp[lowestNode] > timestamp[node] {
-				lowestNode = node
-			}
+		} else if fScore == f[lowestNode] &&
+		          tieBreaksBefore(node, g[node], fScore - g[node], timestamp[node],
+		                          lowestNode, g[lowestNode], fScore - g[lowestNode], timestamp[lowestNode]) {
+			lowestNode = node
 		}
 	}
 	return lowestNode
@@ -147,7 +205,7 @@ func findPath(start, goal Node) []Node {
 				continue // Closed node
 			}
 			tentativeG := g[node] + costToNeighbour(node, neighbour)
-			if tentativeG < g[neighbour] {
+			if tentativeG < gScore(neighbour) {
 				parent[neighbour] = node
 				g[neighbour]      = tentativeG
 				f[neighbour]      = g[neighbour] + heuristic(neighbour, goal)
@@ -162,9 +220,12 @@ func findPath(start, goal Node) []Node {
 }
 
 func ThetaStar(start, goal Node) []Node {
+	if !sameComponent(start, goal) {
+		return []Node{}
+	}
 	resetPathfindingStructures()
 	open[start] = true
-	heuristic   = StraightLineDist
+	heuristic   = searchHeuristic(StraightLineDist)
 	g[start]    = 0
 	f[start]    = g[start] + heuristic(start, goal)
 
@@ -182,7 +243,7 @@ func ThetaStar(start, goal Node) []Node {
 			if hasParent && lineOfSight(par, neighbour) {
 				/* Path 2 */
 				tentativeG := g[par] + StraightLineDist(par, neighbour)
-				if tentativeG < g[neighbour] {
+				if tentativeG < gScore(neighbour) {
 					parent[neighbour] = par
 					g[neighbour]      = tentativeG
 					f[neighbour]      = g[neighbour] + heuristic(neighbour, goal)
@@ -192,7 +253,7 @@ func ThetaStar(start, goal Node) []Node {
 			} else {
 				/* Path 1 */
 				tentativeG := g[node] + costToNeighbour(node, neighbour)
-				if tentativeG < g[neighbour] {
+				if tentativeG < gScore(neighbour) {
 					parent[neighbour] = node
 					g[neighbour]      = tentativeG
 					f[neighbour]      = g[neighbour] + heuristic(neighbour, goal)
@@ -207,10 +268,25 @@ func ThetaStar(start, goal Node) []Node {
 	return []Node{}
 }
 
+type Connectivity int
+const (
+	EightConnected Connectivity = iota // Diagonals need at least one open side cell
+	EightConnectedNoCornerCutting      // Diagonals need both side cells open
+	FourConnected                      // No diagonals
+)
+
+var connectivity = EightConnected
+
 /*
- * Nodes outside the map are considered closed.
+ * Nodes outside the map are considered closed. With --agent-size only
+ * the cells where the agent fits are open, see agentFits.
  */
 func isOpen(x, y int) bool {
+	return isCellOpen(x, y) && agentFits(x, y, nodeModel)
+}
+
+// Whether cell (x,y) of the map is open, whatever the size of the agent
+func isCellOpen(x, y int) bool {
 	w := len(grid[0])
 	h := len(grid)
 	return x >= 0 && x < w &&
@@ -218,10 +294,34 @@ func isOpen(x, y int) bool {
 	       !grid[y][x]
 }
 
-// Small bug: If we begin in the corner of an L shape of blocked cells,
-// we will only get diagonal neighbours.
-// Possible solution: get neighbours based on the direction.
-// The direction between node and parent[node] can easily be inferred.
+// Whether a diagonal move between cell centers with the given side cells
+// is allowed with the current connectivity
+func diagonalAllowed(side1Open, side2Open bool) bool {
+	if connectivity == EightConnectedNoCornerCutting {
+		return side1Open && side2Open
+	}
+	return side1Open || side2Open
+}
+
+// Whether a diagonal move between grid corners may cross the given cell.
+// The move lies inside the cell, so the cell must be open. Without corner
+// cutting the move may not touch the corner of a blocked cell either, so
+// the four cells that share an edge with the crossed cell must be open.
+// The rule only depends on the cells around the move, which makes moves
+// symmetric: a node is always a neighbour of its neighbours.
+func canCrossCell(x, y int) bool {
+	if !isOpen(x, y) || connectivity == FourConnected {
+		return false
+	}
+	if connectivity == EightConnectedNoCornerCutting {
+		return isOpen(x, y-1) && isOpen(x+1, y) && isOpen(x, y+1) && isOpen(x-1, y)
+	}
+	return true
+}
+
+// An orthogonal move runs along a grid edge and needs an open cell on
+// at least one side, or room for a larger agent at both ends. A diagonal
+// move crosses a cell, see canCrossCell.
 func getTraversableNodes(node Node) []Node {
     /*
 	Traversal is done between nodes (ie grid edges) and open/closed
@@ -242,6 +342,9 @@ func getTraversableNodes(node Node) []Node {
 	cells surrounding N contain their respective indices to check for
 	open/closed cells in the map.
     */
+	if nodeModel == CellCenters {
+		return getTraversableCells(node)
+	}
 	neighbours := []Node{}
 	x := node.X
 	y := node.Y
@@ -250,100 +353,161 @@ func getTraversableNodes(node Node) []Node {
 	seOpen := isOpen(x,   y)
 	swOpen := isOpen(x-1, y)
 
-	if nwOpen || neOpen { // We can traverse north
+	north, east, south, west := nwOpen || neOpen, neOpen || seOpen, seOpen || swOpen, swOpen || nwOpen
+	if agentSize > 0 {
+		// The agent must fit at both ends, see agentFitsAtCorner
+		here := agentFitsAtCorner(x, y)
+		north = here && agentFitsAtCorner(x, y-1)
+		east  = here && agentFitsAtCorner(x+1, y)
+		south = here && agentFitsAtCorner(x, y+1)
+		west  = here && agentFitsAtCorner(x-1, y)
+	}
+
+	if north { // We can traverse north
 		neighbours = append(neighbours, NewNode(x, y-1))
 	}
-	if neOpen || seOpen { // We can traverse east
+	if east { // We can traverse east
 		neighbours = append(neighbours, NewNode(x+1, y))
 	}
-	if seOpen || swOpen { // We can traverse south
+	if south { // We can traverse south
 		neighbours = append(neighbours, NewNode(x, y+1))
 	}
-	if swOpen || nwOpen { // We can traverse west
+	if west { // We can traverse west
 		neighbours = append(neighbours, NewNode(x-1, y))
 	}
 
-	if nwOpen && (neOpen || swOpen) { // We can traverse north-west
+	if canCrossCell(x-1, y-1) { // We can traverse north-west
 		neighbours = append(neighbours, NewNode(x-1, y-1))
 	}
-	if neOpen && (nwOpen || seOpen) { // We can traverse north-east
+	if canCrossCell(x, y-1) { // We can traverse north-east
 		neighbours = append(neighbours, NewNode(x+1, y-1))
 	}
-	if seOpen && (neOpen || swOpen) { // We can traverse south-east
+	if canCrossCell(x, y) { // We can traverse south-east
 		neighbours = append(neighbours, NewNode(x+1, y+1))
 	}
-	if swOpen && (nwOpen || seOpen) { // We can traverse south-west
+	if canCrossCell(x-1, y) { // We can traverse south-west
 		neighbours = append(neighbours, NewNode(x-1, y+1))
 	}
 
 	return neighbours
 }
 
-// Adapted Bresenham's Line Algorithm from link below
-// https://web.archive.org/web/20190717211246/http://aigamedev.com/open/tutorials/theta-star-any-angle-paths/
+// Line of sight between two nodes for the any-angle algorithms, see
+// segmentClear for the rules. With 4-connectivity only straight
+// horizontal and vertical lines are allowed. Larger agents in the corner
+// model run along such lines like orthogonal moves, see agentLineClear.
+// In the cell-center model every cell is split into 2x2 half cells, so
+// cell centers become corners of the half cells and both node models
+// share the same test.
 func lineOfSight(start, end Node) bool {
-	x0 := start.X
-	y0 := start.Y
-	x1 := end.X
-	y1 := end.Y
-	dx := x1-x0
-	dy := y1-y0
-	f  := 0
-
-	var sx, sy int
-	if dx < 0 {
-		dx = -dx
-		sx = -1
-	} else {
-		sx = 1
+	if connectivity == FourConnected && start.X != end.X && start.Y != end.Y {
+		return false
 	}
-	if dy < 0 {
-		dy = -dy
-		sy = -1
-	} else {
-		sy = 1
+	if nodeModel == CellCenters {
+		halfCellOpen := func(x, y int) bool {
+			return isOpen(floorDiv(x, 2), floorDiv(y, 2))
+		}
+		return isOpen(start.X, start.Y) &&
+		       segmentClear(2*start.X+1, 2*start.Y+1, 2*end.X+1, 2*end.Y+1, halfCellOpen, diagonalAllowed)
+	}
+	if agentSize > 0 && (start.X == end.X || start.Y == end.Y) {
+		points := NodesToPoints([]Node{start, end})
+		return agentLineClear(points[0], points[1], isOpen)
 	}
+	return segmentClear(start.X, start.Y, end.X, end.Y, isOpen, canTouchCorner)
+}
 
-	if dx >= dy {
-		for x0 != x1 {
-			f += dy
-			if f >= dx {
-				if grid[y0 + (sy-1)/2][x0 + (sx-1)/2] {
-					return false
-				}
-				y0 += sy
-				f -= dx
-			}
-			if f != 0 && grid[y0 + (sy-1)/2][x0 + (sx-1)/2] {
-				return false
-			}
-			if y0 == len(grid[0]) || // Otherwise we will go out of bounds
-			   (dy == 0 && grid[y0][x0 + (sx-1)/2] && grid[y0-1][x0 + (sx-1)/2]) {
+// Whether a line between grid corners may touch two cells at a corner
+// without entering them. Without corner cutting both must be open.
+func canTouchCorner(side1Open, side2Open bool) bool {
+	return connectivity != EightConnectedNoCornerCutting || (side1Open && side2Open)
+}
+
+/*
+ * Tests the line between grid corners (x0,y0) and (x1,y1) exactly, with
+ * integer arithmetic only:
+ *  - Every cell whose interior the line passes through must be open.
+ *  - A horizontal or vertical line runs along grid edges and needs an
+ *    open cell on at least one side of every edge, like an orthogonal
+ *    move.
+ *  - Any other line touches two cells without entering them at every
+ *    grid corner it passes through, and at its end points touches the
+ *    two cells next to the first and last cell it enters. These pairs
+ *    of side cells must pass canTouch, which decides whether the line
+ *    may squeeze through zero-width gaps.
+ * cellOpen decides which cells are open, including cells outside the
+ * map, so a line along the border only needs the inside cell to be open.
+ * Based on the supercover variant of Bresenham's algorithm: the line is
+ * followed one column of cells at a time.
+ */
+func segmentClear(x0, y0, x1, y1 int, cellOpen func(int, int) bool, canTouch func(bool, bool) bool) bool {
+	if x0 > x1 {
+		x0, y0, x1, y1 = x1, y1, x0, y0
+	}
+	dx := x1 - x0
+	dy := y1 - y0
+
+	if dy == 0 {
+		for x := x0; x < x1; x++ {
+			if !cellOpen(x, y0-1) && !cellOpen(x, y0) {
 				return false
 			}
-			x0 += sx
 		}
-	} else {
-		for y0 != y1 {
-			f += dx
-			if f >= dy {
-				if grid[y0 + (sy-1)/2][x0 + (sx-1)/2] {
-					return false
-				}
-				x0 += sx
-				f -= dy
-			}
-			if f != 0 && grid[y0 + (sy-1)/2][x0 + (sx-1)/2] {
+		return true
+	}
+	if dx == 0 {
+		yMin, yMax := y0, y1
+		if dy < 0 {
+			yMin, yMax = y1, y0
+		}
+		for y := yMin; y < yMax; y++ {
+			if !cellOpen(x0-1, y) && !cellOpen(x0, y) {
 				return false
 			}
-			if x0 == len(grid[0]) || // Otherwise we will go out of bounds
-			   (dx == 0 && grid[y0 + (sy-1)/2][x0] && grid[y0 + (sy-1)/2][x0-1]) {
+		}
+		return true
+	}
+
+	// At a grid corner (x,y) on the line, the line leaves the cell
+	// (x-1,y-1-r) and enters the cell (x,y+r), where r is 0 for lines
+	// going down and -1 for lines going up. The side cells are the other
+	// two. At the end points the same cells are next to the first and
+	// last cell that the line enters.
+	r := 0
+	if dy < 0 {
+		r = -1
+	}
+	sidesTouchable := func(x, y int) bool {
+		return canTouch(cellOpen(x, y-1-r), cellOpen(x-1, y+r))
+	}
+
+	// The line crosses x = c at y = num/dx
+	num := y0*dx
+	for c := x0; c < x1; c++ {
+		if num % dx == 0 && !sidesTouchable(c, num/dx) {
+			return false
+		}
+		lo, hi := num, num+dy
+		if dy < 0 {
+			lo, hi = hi, lo
+		}
+		for y := floorDiv(lo, dx); y < -floorDiv(-hi, dx); y++ {
+			if !cellOpen(c, y) {
 				return false
 			}
-			y0 += sy
 		}
+		num += dy
 	}
-	return true
+	return sidesTouchable(x1, y1)
+}
+
+// Integer division rounded down, for b > 0
+func floorDiv(a, b int) int {
+	q := a / b
+	if a % b != 0 && a < 0 {
+		q--
+	}
+	return q
 }
 
 /*
@@ -351,6 +515,9 @@ func lineOfSight(start, end Node) bool {
  */
 func AStarPs(start, goal Node) []Node {
 	path := AStar(start, goal)
+	if len(path) < 2 {
+		return path
+	}
     smoothPath := []Node{start}
     for i := 1; i < len(path)-1; i++ {
 		last := smoothPath[len(smoothPath)-1]
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"testing"
+)
+
+/*
+ * Compares getTraversableNodes with moveAllowedSpec in every node of a
+ * 4x4 map, for every configuration of the 2x2 cells in the middle, with
+ * the surrounding cells all open or all blocked, in every node model
+ * and connectivity. Also checks that every move can be made backwards.
+ */
+func TestNeighbours(t *testing.T) {
+	keepGlobals(t)
+
+	// The cells around node (2,2) in the order nw, ne, se, sw
+	middle := [][2]int{{1,1}, {2,1}, {2,2}, {1,2}}
+	for config := 0; config < 16; config++ {
+		for _, ringBlocked := range([]bool{false, true}) {
+			grid = newGrid(4, 4, ringBlocked)
+			desc := ""
+			for i, c := range(middle) {
+				blocked := config & (1 << uint(i)) != 0
+				grid[c[1]][c[0]] = blocked
+				if blocked {
+					desc += "@"
+				} else {
+					desc += "."
+				}
+			}
+			if ringBlocked {
+				desc += " in a blocked ring"
+			} else {
+				desc += " in an open ring"
+			}
+
+			for _, model := range(allNodeModels) {
+				for _, c := range(allConnectivities) {
+					nodeModel, connectivity = model, c
+					testNeighboursOfGrid(t, fmt.Sprintf("cells %s, %s model, %s-connectivity", desc, nodeModelName(model), connectivityName(c)))
+				}
+			}
+		}
+	}
+}
+
+func testNeighboursOfGrid(t *testing.T, prefix string) {
+	for y := 0; y <= len(grid); y++ {
+		for x := 0; x <= len(grid[0]); x++ {
+			node := NewNode(x, y)
+			found := map[Node]bool{}
+			for _, neighbour := range(getTraversableNodes(node)) {
+				found[neighbour] = true
+				if !moveAllowedSpec(node, neighbour) {
+					t.Errorf("%s: unexpected move (%d,%d) -> (%d,%d)", prefix, x, y, neighbour.X, neighbour.Y)
+				}
+				backwards := false
+				for _, n := range(getTraversableNodes(neighbour)) {
+					backwards = backwards || n == node
+				}
+				if !backwards {
+					t.Errorf("%s: move (%d,%d) -> (%d,%d) cannot be made backwards", prefix, x, y, neighbour.X, neighbour.Y)
+				}
+			}
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					neighbour := NewNode(x+dx, y+dy)
+					if moveAllowedSpec(node, neighbour) && !found[neighbour] {
+						t.Errorf("%s: missing move (%d,%d) -> (%d,%d)", prefix, x, y, neighbour.X, neighbour.Y)
+					}
+				}
+			}
+		}
+	}
+}
+
+var allScenarios = flag.Bool("all-scenarios", false, "search every scenario of ../maps in TestScenarioLengths")
+
+/*
+ * Compares AStar path lengths with the optimal lengths of the scenarios
+ * that ship with the maps, and the bidirectional algorithms, the subgoal
+ * graph and ALT with AStar. The optimal lengths assume cell centers
+ * without corner cutting, which movingaiLength must match exactly, and
+ * AStar too in that model.
+ * Paths between grid corners with 8-connectivity may also run along
+ * walls, so they can only be shorter. The files have over 25000
+ * scenarios, up to 4847 cells long on the narrow maze, and searching
+ * them all takes many hours, so only 5 scenarios of up to 64 cells of
+ * each file are searched unless -all-scenarios is given. -short skips
+ * the test.
+ */
+func TestScenarioLengths(t *testing.T) {
+	if testing.Short() {
+		t.Skip("searches the 512x512 maps of ../maps")
+	}
+	keepGlobals(t)
+	paths, _ := filepath.Glob("../maps/*/*.scen")
+	if len(paths) == 0 {
+		t.Fatal("No scenarios files in ../maps")
+	}
+	for _, path := range(paths) {
+		if *allScenarios {
+			testScenarioLengths(t, path, math.MaxInt32, math.Inf(1))
+		} else {
+			testScenarioLengths(t, path, 5, 64)
+		}
+	}
+}
+
+// Checks n scenarios of a file with optimal lengths up to maxLength
+func testScenarioLengths(t *testing.T, path string, n int, maxLength float64) {
+	scenarios, err := LoadScenarios(path)
+	if err != nil {
+		t.Fatalf("%s: %s", path, err.Error())
+	}
+	short := []Scenario{}
+	for _, s := range(scenarios) {
+		if s.OptimalLength <= maxLength {
+			short = append(short, s)
+		}
+	}
+	if len(short) == 0 {
+		t.Fatalf("%s has no scenarios of up to %g cells", path, maxLength)
+	}
+	mapPath := filepath.Join(filepath.Dir(path), short[0].MapName)
+	loaded, err := LoadMap(mapPath)
+	if err != nil {
+		t.Fatalf("%s: %s", mapPath, err.Error())
+	}
+
+	const epsilon = 1e-4
+	for _, model := range(allNodeModels) {
+		nodeModel = model
+		if model == CellCenters {
+			connectivity = EightConnectedNoCornerCutting
+		} else {
+			connectivity = EightConnected
+		}
+		setGrid(loaded)
+		for _, s := range(selectScenarios(short, n)) {
+			if length, _ := movingaiLength(loaded, s.Start, s.Goal); math.Abs(length - s.OptimalLength) > epsilon {
+				t.Errorf("%s: (%d,%d) -> (%d,%d) has the movingai length %f, the optimal length is %f",
+					path, s.Start.X, s.Start.Y, s.Goal.X, s.Goal.Y, length, s.OptimalLength)
+			}
+			length := PathLength(AStar(s.Start, s.Goal))
+			for _, name := range([]string{"bidijkstra", "biastar", "subgoal", "alt"}) {
+				other := PointPathLength(MustParsePathfindingFunction(name)(s.Start, s.Goal))
+				if math.Abs(other - length) > epsilon {
+					t.Errorf("%s: (%d,%d) -> (%d,%d) has length %f with %s in the %s model, %f with astar",
+						path, s.Start.X, s.Start.Y, s.Goal.X, s.Goal.Y, other, name, nodeModelName(model), length)
+				}
+			}
+			if (model == CellCenters && math.Abs(length - s.OptimalLength) > epsilon) ||
+			   (model == GridCorners && length > s.OptimalLength + epsilon) {
+				t.Errorf("%s: (%d,%d) -> (%d,%d) has length %f in the %s model with %s-connectivity, the optimal length is %f",
+					path, s.Start.X, s.Start.Y, s.Goal.X, s.Goal.Y, length, nodeModelName(model), connectivityName(connectivity), s.OptimalLength)
+			}
+		}
+	}
+}
+
+/*
+ * Compares lineOfSight with pointsVisibleReference between random nodes,
+ * including nodes on the map border, on random maps of up to 8x8 cells
+ * that are often not square, in every node model and connectivity. The
+ * floating-point test of the smoothing must agree where the line is not
+ * a single point.
+ */
+func TestLineOfSight(t *testing.T) {
+	keepGlobals(t)
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 300; i++ {
+		grid = randomGrid(random, 8, 8, 1)
+		for _, model := range(allNodeModels) {
+			nodeModel = model
+			maxX, maxY := maxNodeCoordinates()
+			for j := 0; j < 50; j++ {
+				start  := NewNode(random.Intn(maxX+1), random.Intn(maxY+1))
+				end    := NewNode(random.Intn(maxX+1), random.Intn(maxY+1))
+				points := NodesToPoints([]Node{start, end})
+				for _, c := range(allConnectivities) {
+					connectivity = c
+					prefix   := fmt.Sprintf("map %d, %s model, %s-connectivity", i, nodeModelName(model), connectivityName(c))
+					got      := lineOfSight(start, end)
+					expected := pointsVisibleReference(points[0], points[1], isOpen)
+					if got != expected {
+						t.Errorf("%s: line of sight (%d,%d) -> (%d,%d) is %t, expected %t\n%s",
+							prefix, start.X, start.Y, end.X, end.Y, got, expected, gridString(grid))
+					}
+					canTouch := canTouchCorner
+					if model == CellCenters {
+						canTouch = diagonalAllowed
+					}
+					floatGot := (c != FourConnected || start.X == end.X || start.Y == end.Y) &&
+					            floatSegmentClear(points[0], points[1], isOpen, canTouch)
+					if start != end && floatGot != expected {
+						t.Errorf("%s: floating-point line of sight (%d,%d) -> (%d,%d) is %t, expected %t\n%s",
+							prefix, start.X, start.Y, end.X, end.Y, floatGot, expected, gridString(grid))
+					}
+				}
+			}
+		}
+	}
+}
+
+/*
+ * A* between nearby nodes in the corner of a large open map must only
+ * store the nodes that it reaches, whatever the size of the map, and the
+ * next search must start from scratch. Unreached nodes have infinite g
+ * scores.
+ */
+func TestSearchStateGrowsWithSearch(t *testing.T) {
+	keepGlobals(t)
+	nodeModel, connectivity = GridCorners, EightConnected
+	setGrid(newGrid(300, 300, false))
+	start, goal := NewNode(0, 0), NewNode(3, 2)
+	for i := 0; i < 2; i++ {
+		peakStoredNodes = 0
+		path := AStar(start, goal)
+		if math.Abs(PathLength(path) - (1 + 2*SQRT2)) > 1e-9 {
+			t.Errorf("search %d: the path has length %f, expected %f", i, PathLength(path), 1 + 2*SQRT2)
+		}
+		if len(g) > 50 || peakStoredNodes > 300 {
+			t.Errorf("search %d: %d g score(s) and %d stored node(s) for a path of %d nodes", i, len(g), peakStoredNodes, len(path))
+		}
+	}
+	if score := gScore(NewNode(200, 200)); !math.IsInf(score, 1) {
+		t.Errorf("the unreached node (200,200) has the g score %f", score)
+	}
+}
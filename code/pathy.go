@@ -8,6 +8,7 @@ import (
 	"strconv"
 	"time"
 	"math"
+	"runtime"
 )
 
 type PathyMode int
@@ -17,17 +18,34 @@ const (
 	BenchAndDrawSingle
 	BenchMultiple
 	BenchAndDrawMultiple
+	GenScenarios
+	GenMap
+	Components
+	Stats
+	ReplanChanges
+	SelectLandmarksMode
+	Compare
+	CompareTieBreaking
+	Transform
 )
 
 type PathyParameters struct {
 	Mode     PathyMode
 	InPath   string
+	InPaths  []string
 	OutPath  string
 	Scale    int
-	Algo     func(Node, Node) []Node
+	Algo     Algorithm
+	Algos    []Algorithm
 	N        int
 	Trials   int
+	Seed     int64
+	Generator     string
+	Strategy      string // Landmark selection strategy
+	Width, Height int
+	Param         float64 // Generator parameter, negative means default
 	StartX, StartY, GoalX, GoalY int
+	Steps         []TransformStep
 }
 
 var counter = 0
@@ -38,6 +56,9 @@ func readNextArg() string {
 }
 
 func main() {
+	extractOptions()
+	applyOptions()
+
 	// Print help
 	if len(os.Args) < 2 {
 		fmt.Printf("%s is a tool for visualization and benchmarking of pathfinding algorithms.\n\n", os.Args[0])
@@ -50,8 +71,33 @@ func main() {
 		fmt.Println("To benchmark multiple scenarios:")
 		fmt.Printf("    %s multiple scenarios_file algorithm n trials\n", os.Args[0])
 		fmt.Println("To benchmark multiple scenarios and draw their paths:")
-		fmt.Printf("    %s multiple scenarios_file algorithm n trials output_dir scale\n\n", os.Args[0])
-		fmt.Println("Accepted algorithms are \"dijkstra\", \"astar\", \"astar-ps\" and \"thetastar\". N is the amount of scenarios to pick from the file. They are evenly spread out in terms of problem size.")
+		fmt.Printf("    %s multiple scenarios_file algorithm n trials output_dir scale\n", os.Args[0])
+		fmt.Println("To compare algorithms on the same scenarios, checking that the octile-optimal ones agree:")
+		fmt.Printf("    %s compare scenarios_file n trials algorithm...\n", os.Args[0])
+		fmt.Println("To compare the tie-breaking policies of an algorithm on the same scenarios:")
+		fmt.Printf("    %s tie-breaking scenarios_file n trials algorithm\n", os.Args[0])
+		fmt.Println("To generate n random scenarios for a map:")
+		fmt.Printf("    %s generate-scenarios map_file output_scenarios_file n seed\n", os.Args[0])
+		fmt.Println("To generate a random map:")
+		fmt.Printf("    %s generate-map generator width height seed output_map_file [parameter]\n", os.Args[0])
+		fmt.Println("To apply a comma-separated chain of morphology and geometric steps to a map and save the result:")
+		fmt.Printf("    %s transform map_file steps output_map_file\n", os.Args[0])
+		fmt.Println("To apply them to a map and its scenarios, moving the starts and goals with their cells:")
+		fmt.Printf("    %s transform map_file steps output_map_file scenarios_file output_scenarios_file\n", os.Args[0])
+		fmt.Println("To list the connected components of a map:")
+		fmt.Printf("    %s components map_file\n", os.Args[0])
+		fmt.Println("To print statistics about a map or a scenarios file:")
+		fmt.Printf("    %s stats map_or_scenarios_file\n", os.Args[0])
+		fmt.Println("To replay the cell changes of a changes file, comparing D* Lite replanning with A* from scratch:")
+		fmt.Printf("    %s replan map_file start_x start_y goal_x goal_y changes_file\n", os.Args[0])
+		fmt.Println("To select n landmarks for alt and save their distances to every node:")
+		fmt.Printf("    %s landmarks map_file n strategy seed output_file\n\n", os.Args[0])
+		printAlgorithmsHelp()
+		fmt.Println("N is the amount of scenarios to pick from the file. They are evenly spread out in terms of problem size.")
+		fmt.Println("Accepted landmark strategies are \"farthest\" and \"random\".")
+		fmt.Println("Accepted generators are \"random\" (parameter: density of blocked cells), \"rooms\" (parameter: room width), \"caves\" (parameter: initial density of blocked cells) and \"maze\" (parameter: corridor width).")
+		fmt.Println()
+		printOptionsHelp()
 		os.Exit(0)
 	}
 
@@ -67,21 +113,45 @@ func main() {
 			p = getSingleModeParameters()
 		case "multiple":
 			p = getMultipleModeParameters()
+		case "generate-scenarios":
+			p = getGenerateScenariosModeParameters()
+		case "generate-map":
+			p = getGenerateMapModeParameters()
+		case "components":
+			p = getComponentsModeParameters()
+		case "stats":
+			p = getStatsModeParameters()
+		case "replan":
+			p = getReplanModeParameters()
+		case "landmarks":
+			p = getLandmarksModeParameters()
+		case "compare":
+			p = getCompareModeParameters()
+		case "tie-breaking":
+			p = getTieBreakingModeParameters()
+		case "transform":
+			p = getTransformModeParameters()
 		default:
-			fmt.Printf("Unknown mode \"%s\", accepted modes are \"draw\", \"single\" and \"multiple\"\n", modeString)
+			fmt.Printf("Unknown mode \"%s\", accepted modes are \"draw\", \"single\", \"multiple\", \"generate-scenarios\", \"generate-map\", \"components\", \"stats\", \"replan\", \"landmarks\", \"compare\", \"tie-breaking\" and \"transform\"\n", modeString)
 			os.Exit(1)
 	}
 
+	// The smoothing applies to every algorithm that a mode runs
+	p.Algo = withSmoothing(p.Algo)
+	for i := range(p.Algos) {
+		p.Algos[i] = withSmoothing(p.Algos[i])
+	}
+
 	// Check some of the arguments
 	if (p.Mode == Draw || p.Mode == BenchAndDrawSingle || p.Mode == BenchAndDrawMultiple) && p.Scale < 1 {
 		fmt.Println("Scale must be a positive integer.")
 		os.Exit(1)
 	}
-	if (p.Mode == BenchMultiple || p.Mode == BenchAndDrawMultiple) && p.N < 1 {
+	if (p.Mode == BenchMultiple || p.Mode == BenchAndDrawMultiple || p.Mode == GenScenarios || p.Mode == SelectLandmarksMode || p.Mode == Compare || p.Mode == CompareTieBreaking) && p.N < 1 {
 		fmt.Println("N must be a positive integer.")
 		os.Exit(1)
 	}
-	if (p.Mode == BenchSingle || p.Mode == BenchAndDrawSingle || p.Mode == BenchMultiple || p.Mode == BenchAndDrawMultiple) && p.Trials < 1 {
+	if (p.Mode == BenchSingle || p.Mode == BenchAndDrawSingle || p.Mode == BenchMultiple || p.Mode == BenchAndDrawMultiple || p.Mode == Compare || p.Mode == CompareTieBreaking) && p.Trials < 1 {
 		fmt.Println("Trials must be a positive integer.")
 		os.Exit(1)
 	}
@@ -94,6 +164,24 @@ func main() {
 			runSingleMode(p)
 		case BenchMultiple, BenchAndDrawMultiple:
 			runMultipleMode(p)
+		case GenScenarios:
+			runGenerateScenariosMode(p)
+		case GenMap:
+			runGenerateMapMode(p)
+		case Components:
+			runComponentsMode(p)
+		case Stats:
+			runStatsMode(p)
+		case ReplanChanges:
+			runReplanMode(p)
+		case SelectLandmarksMode:
+			runLandmarksMode(p)
+		case Compare:
+			runCompareMode(p)
+		case CompareTieBreaking:
+			runTieBreakingMode(p)
+		case Transform:
+			runTransformMode(p)
 		default:
 			panic("Assertion failed: unexpected mode")
 	}
@@ -125,7 +213,7 @@ func getSingleModeParameters() PathyParameters {
 	p.StartY = MustParseInt(readNextArg())
 	p.GoalX  = MustParseInt(readNextArg())
 	p.GoalY  = MustParseInt(readNextArg())
-	p.Algo   = MustParsePathfindingFunction(readNextArg())
+	p.Algo   = MustParseAlgorithm(readNextArg())
 	p.Trials = MustParseInt(readNextArg())
 	if len(os.Args) == 11 {
 		p.Mode    = BenchAndDrawSingle
@@ -144,7 +232,7 @@ func getMultipleModeParameters() PathyParameters {
 	}
 	p := PathyParameters{}
 	p.InPath = readNextArg()
-	p.Algo   = MustParsePathfindingFunction(readNextArg())
+	p.Algo   = MustParseAlgorithm(readNextArg())
 	p.N      = MustParseInt(readNextArg())
 	p.Trials = MustParseInt(readNextArg())
 	if len(os.Args) == 8 {
@@ -157,16 +245,160 @@ func getMultipleModeParameters() PathyParameters {
 	return p
 }
 
+func getGenerateScenariosModeParameters() PathyParameters {
+	if len(os.Args) != 6 {
+		fmt.Printf("Wrong number of arguments. Run %s without parameters for more info.\n", os.Args[0])
+		os.Exit(1)
+	}
+	p := PathyParameters{}
+	p.Mode    = GenScenarios
+	p.InPath  = readNextArg()
+	p.OutPath = readNextArg()
+	p.N       = MustParseInt(readNextArg())
+	p.Seed    = int64(MustParseInt(readNextArg()))
+	return p
+}
+
+func getGenerateMapModeParameters() PathyParameters {
+	if len(os.Args) != 7 && len(os.Args) != 8 {
+		fmt.Printf("Wrong number of arguments. Run %s without parameters for more info.\n", os.Args[0])
+		os.Exit(1)
+	}
+	p := PathyParameters{}
+	p.Mode      = GenMap
+	p.Generator = readNextArg()
+	p.Width     = MustParseInt(readNextArg())
+	p.Height    = MustParseInt(readNextArg())
+	p.Seed      = int64(MustParseInt(readNextArg()))
+	p.OutPath   = readNextArg()
+	p.Param     = -1
+	if len(os.Args) == 8 {
+		p.Param = MustParseFloat(readNextArg())
+		if p.Param < 0 {
+			fmt.Println("The generator parameter must not be negative.")
+			os.Exit(1)
+		}
+	}
+	return p
+}
+
+func getComponentsModeParameters() PathyParameters {
+	if len(os.Args) != 3 {
+		fmt.Printf("Wrong number of arguments. Run %s without parameters for more info.\n", os.Args[0])
+		os.Exit(1)
+	}
+	p := PathyParameters{}
+	p.Mode   = Components
+	p.InPath = readNextArg()
+	return p
+}
+
+func getStatsModeParameters() PathyParameters {
+	if len(os.Args) != 3 {
+		fmt.Printf("Wrong number of arguments. Run %s without parameters for more info.\n", os.Args[0])
+		os.Exit(1)
+	}
+	p := PathyParameters{}
+	p.Mode   = Stats
+	p.InPath = readNextArg()
+	return p
+}
+
+func getReplanModeParameters() PathyParameters {
+	if len(os.Args) != 8 {
+		fmt.Printf("Wrong number of arguments. Run %s without parameters for more info.\n", os.Args[0])
+		os.Exit(1)
+	}
+	p := PathyParameters{}
+	p.Mode    = ReplanChanges
+	p.InPath  = readNextArg()
+	p.StartX  = MustParseInt(readNextArg())
+	p.StartY  = MustParseInt(readNextArg())
+	p.GoalX   = MustParseInt(readNextArg())
+	p.GoalY   = MustParseInt(readNextArg())
+	p.InPaths = []string{readNextArg()}
+	return p
+}
+
+func getLandmarksModeParameters() PathyParameters {
+	if len(os.Args) != 7 {
+		fmt.Printf("Wrong number of arguments. Run %s without parameters for more info.\n", os.Args[0])
+		os.Exit(1)
+	}
+	p := PathyParameters{}
+	p.Mode      = SelectLandmarksMode
+	p.InPath    = readNextArg()
+	p.N         = MustParseInt(readNextArg())
+	p.Strategy  = strings.ToLower(readNextArg())
+	p.Seed      = int64(MustParseInt(readNextArg()))
+	p.OutPath   = readNextArg()
+	return p
+}
+
+func getCompareModeParameters() PathyParameters {
+	if len(os.Args) < 6 {
+		fmt.Printf("Wrong number of arguments. Run %s without parameters for more info.\n", os.Args[0])
+		os.Exit(1)
+	}
+	p := PathyParameters{}
+	p.Mode   = Compare
+	p.InPath = readNextArg()
+	p.N      = MustParseInt(readNextArg())
+	p.Trials = MustParseInt(readNextArg())
+	for counter < len(os.Args) {
+		p.Algos = append(p.Algos, MustParseAlgorithm(readNextArg()))
+	}
+	return p
+}
+
+func getTieBreakingModeParameters() PathyParameters {
+	if len(os.Args) != 6 {
+		fmt.Printf("Wrong number of arguments. Run %s without parameters for more info.\n", os.Args[0])
+		os.Exit(1)
+	}
+	p := PathyParameters{}
+	p.Mode   = CompareTieBreaking
+	p.InPath = readNextArg()
+	p.N      = MustParseInt(readNextArg())
+	p.Trials = MustParseInt(readNextArg())
+	p.Algo   = MustParseAlgorithm(readNextArg())
+	if !p.Algo.supports("tie-breaking") {
+		fmt.Printf("%s does not use --tie-breaking\n", p.Algo.Name)
+		os.Exit(1)
+	}
+	return p
+}
+
+func getTransformModeParameters() PathyParameters {
+	if len(os.Args) != 5 && len(os.Args) != 7 {
+		fmt.Printf("Wrong number of arguments. Run %s without parameters for more info.\n", os.Args[0])
+		os.Exit(1)
+	}
+	p := PathyParameters{}
+	p.Mode    = Transform
+	p.InPath  = readNextArg()
+	p.Steps   = MustParseTransforms(readNextArg())
+	p.OutPath = readNextArg()
+	if len(os.Args) == 7 {
+		p.InPaths = []string{readNextArg(), readNextArg()} // Scenarios in and out
+		if cellCosts != nil {
+			fmt.Println("transform does not move the costs of --costs with the scenarios")
+			os.Exit(1)
+		}
+	}
+	return p
+}
+
 func runDrawMode(p PathyParameters) {
 	if p.Mode != Draw {
 		panic("Assertion failed: unexpected mode")
 	}
-	var err error
-	grid, err = LoadMap(p.InPath)
+	loaded, err := LoadMap(p.InPath)
 	if err != nil {
 		fmt.Printf("Error reading file \"%s\": %s\n", p.InPath, err.Error())
 		os.Exit(1)
 	}
+	setGrid(loaded)
 	img := MakeMapImage(p.Scale)
 	err  = SaveImage(img, p.OutPath)
 	if err != nil {
@@ -179,20 +411,41 @@ func runSingleMode(p PathyParameters) {
 	if p.Mode != BenchSingle && p.Mode != BenchAndDrawSingle {
 		panic("Assertion failed: unexpected mode")
 	}
-	var err error
-	grid, err = LoadMap(p.InPath)
+	loaded, err := LoadMap(p.InPath)
 	if err != nil {
 		fmt.Printf("Error reading file \"%s\": %s\n", p.InPath, err.Error())
 		os.Exit(1)
 	}
+	loaded = ApplyMorphology(loaded, morphologySteps)
+	setGrid(loaded)
+	preprocessAlgorithm(p.Algo, p.InPath)
 
 	start := NewNode(p.StartX, p.StartY)
 	goal  := NewNode(p.GoalX,  p.GoalY)
-	path, turns, pathLen, avgAngle, avgRuntime := testOneScenario(start, goal, p.Algo, p.Trials)
-	fmt.Printf("Stats: %d turn(s), length %.1f, avg angle %.1f rad (%.1f deg), runtime %dms\n", turns, pathLen, avgAngle, avgAngle*radToDeg, avgRuntime)
+	path, turns, pathLen, avgAngle, avgRuntime, avgAllocated, stored := testOneScenario(start, goal, p.Algo.Find, p.Trials)
+	fmt.Printf("Stats: %d turn(s), length %.1f, avg angle %.1f rad (%.1f deg), runtime %dms, allocated %.2fMB, stored nodes %d\n", turns, pathLen, avgAngle, avgAngle*radToDeg, avgRuntime, avgAllocated, stored)
+	fmt.Printf("Shape: %s\n", ComputePathMetrics(path))
+	if err := ValidatePath(path, start, goal, p.Algo.Path); err != nil {
+		fmt.Printf("Invalid path of %s: %s\n", p.Algo.Name, err.Error())
+		os.Exit(1)
+	}
+	if len(expansionsPerDirection) > 0 {
+		fmt.Printf("Expansions: %d forward, %d backward\n", expansionsPerDirection[0], expansionsPerDirection[1])
+	} else if expansions > 0 {
+		fmt.Printf("Expansions: %d\n", expansions)
+	}
+	reportHPAFallbacks()
+	reportSubgoalFallbacks()
+	if len(anytimeSolutions) > 0 {
+		fmt.Println("Solutions of the last trial:")
+		for _, s := range(anytimeSolutions) {
+			fmt.Printf("    weight %.2f: length %.1f after %.3fms\n", s.Weight, s.Length, float64(s.Time.Microseconds())/1000)
+		}
+	}
 
 	if p.Mode == BenchAndDrawSingle {
 		img := MakeMapImage(p.Scale)
+		img  = DrawAgentFootprint(img, path, p.Scale)
 		img  = DrawPath(img, path, p.Scale)
 		err  = SaveImage(img, p.OutPath)
 		if err != nil {
@@ -215,11 +468,14 @@ func runMultipleMode(p PathyParameters) {
 	}
 	// Load map
 	mapPath := filepath.Join(filepath.Dir(p.InPath), scenarios[0].MapName)
-	grid, err = LoadMap(mapPath)
+	loaded, err := LoadMap(mapPath)
 	if err != nil {
 		fmt.Printf("Error reading map file \"%s\": %s\n", mapPath, err.Error())
 		os.Exit(1)
 	}
+	loaded = ApplyMorphology(loaded, morphologySteps)
+	setGrid(loaded)
+	preprocessAlgorithm(p.Algo, mapPath)
 
 	// If needed, create an output directory for images
 	if p.Mode == BenchAndDrawMultiple {
@@ -234,22 +490,18 @@ func runMultipleMode(p PathyParameters) {
 		}
 	}
 
-	// Select n evenly spread out scenarios
-	selectedScenarios := []Scenario{}
-	var inc float64
-	if p.N >= len(scenarios) {
-		inc = 1
-		p.N = len(scenarios)
-	} else {
-		inc = float64(len(scenarios)-1) / float64(p.N-1)
-	}
-	for i := 0.0; i < float64(len(scenarios)); i += inc {
-		index := int(i)
-		selectedScenarios = append(selectedScenarios, scenarios[index])
-	}
-	// Assertion
-	if len(selectedScenarios) != p.N {
-		panic("Assertion failed: unexpected number of selected scenarios")
+	selectedScenarios := selectScenarios(scenarios, p.N)
+	p.N = len(selectedScenarios)
+	if len(morphologySteps) > 0 {
+		disconnected := 0
+		for _, s := range(selectedScenarios) {
+			if !sameComponent(s.Start, s.Goal) {
+				disconnected++
+			}
+		}
+		if disconnected > 0 {
+			fmt.Printf("Note: the start and goal of %d scenario(s) are not connected after --morphology\n", disconnected)
+		}
 	}
 
 	// Benchmark and draw scenarios
@@ -257,6 +509,11 @@ func runMultipleMode(p PathyParameters) {
 	sumPathLen    := 0.0
 	sumAvgAngle   := 0.0
 	sumAvgRuntime := 0
+	sumAllocated  := 0.0
+	sumStored     := 0
+	sumExpansions := 0
+	allMetrics    := []PathMetrics{}
+	invalid       := []string{}
 	for _, scenario := range selectedScenarios {
 		// Assertion
 		if scenario.MapName != scenarios[0].MapName {
@@ -265,13 +522,29 @@ func runMultipleMode(p PathyParameters) {
 		sx, sy, gx, gy := scenario.Start.X, scenario.Start.Y, scenario.Goal.X, scenario.Goal.Y
 		start := NewNode(sx,sy)
 		goal  := NewNode(gx,gy)
-		path, turns, pathLen, avgAngle, avgRuntime := testOneScenario(start, goal, p.Algo, p.Trials)
-		fmt.Printf("(%d,%d) -> (%d,%d) stats: %d turn(s), length %.1f, avg angle %.1f rad (%.1f deg), runtime %dms\n", sx, sy, gx, gy, turns, pathLen, avgAngle, avgAngle*radToDeg, avgRuntime)
+		path, turns, pathLen, avgAngle, avgRuntime, avgAllocated, stored := testOneScenario(start, goal, p.Algo.Find, p.Trials)
+		fmt.Printf("(%d,%d) -> (%d,%d) stats: %d turn(s), length %.1f, avg angle %.1f rad (%.1f deg), runtime %dms, allocated %.2fMB, stored nodes %d", sx, sy, gx, gy, turns, pathLen, avgAngle, avgAngle*radToDeg, avgRuntime, avgAllocated, stored)
+		if len(expansionsPerDirection) > 0 {
+			fmt.Printf(", expansions %d forward, %d backward", expansionsPerDirection[0], expansionsPerDirection[1])
+		} else if expansions > 0 {
+			fmt.Printf(", expansions %d", expansions)
+		}
+		fmt.Println()
+		metrics := ComputePathMetrics(path)
+		fmt.Printf("    shape: %s\n", metrics)
+		if err := ValidatePath(path, start, goal, p.Algo.Path); err != nil {
+			fmt.Printf("    INVALID PATH: %s\n", err.Error())
+			invalid = append(invalid, fmt.Sprintf("(%d,%d) -> (%d,%d): %s", sx, sy, gx, gy, err.Error()))
+		}
+		sumExpansions += expansions
+		allMetrics     = append(allMetrics, metrics)
 
 		sumTurnCount  += float64(turns)
 		sumPathLen    += pathLen
 		sumAvgAngle   += avgAngle
 		sumAvgRuntime += avgRuntime
+		sumAllocated  += avgAllocated
+		sumStored     += stored
 
 		if p.Mode == BenchAndDrawMultiple {
 			// Create a nice name for the image
@@ -281,6 +554,7 @@ func runMultipleMode(p PathyParameters) {
 			out   := filepath.Join(p.OutPath, fname)
 
 			img := MakeMapImage(p.Scale)
+			img  = DrawAgentFootprint(img, path, p.Scale)
 			img  = DrawPath(img, path, p.Scale)
 			err  = SaveImage(img, out)
 			if err != nil {
@@ -295,7 +569,199 @@ func runMultipleMode(p PathyParameters) {
 	overallPathLen    := sumPathLen    / float64(p.N)
 	overallAvgAngle   := sumAvgAngle   / float64(p.N)
 	overallAvgRuntime := sumAvgRuntime / p.N
-	fmt.Printf("\nAvg stats: %f turn(s), length %f, avg angle %f rad (%.1f deg), runtime %dms\n", overallTurnCount, overallPathLen, overallAvgAngle, overallAvgAngle*radToDeg, overallAvgRuntime)
+	overallAllocated  := sumAllocated  / float64(p.N)
+	overallStored     := sumStored     / p.N
+	fmt.Printf("\nAvg stats: %f turn(s), length %f, avg angle %f rad (%.1f deg), runtime %dms, allocated %.2fMB, stored nodes %d", overallTurnCount, overallPathLen, overallAvgAngle, overallAvgAngle*radToDeg, overallAvgRuntime, overallAllocated, overallStored)
+	if sumExpansions > 0 {
+		fmt.Printf(", expansions %d", sumExpansions / p.N)
+	}
+	fmt.Printf("\nAvg shape: %s\n", averagePathMetrics(allMetrics))
+	reportHPAFallbacks()
+	reportSubgoalFallbacks()
+
+	if len(invalid) > 0 {
+		fmt.Printf("\n%s returned %d invalid path(s):\n", p.Algo.Name, len(invalid))
+		for _, message := range(invalid) {
+			fmt.Println(message)
+		}
+		os.Exit(1)
+	}
+}
+
+func runGenerateScenariosMode(p PathyParameters) {
+	if p.Mode != GenScenarios {
+		panic("Assertion failed: unexpected mode")
+	}
+	loaded, err := LoadMap(p.InPath)
+	if err != nil {
+		fmt.Printf("Error reading file \"%s\": %s\n", p.InPath, err.Error())
+		os.Exit(1)
+	}
+	setGrid(loaded)
+
+	scenarios, err := GenerateScenarios(filepath.Base(p.InPath), p.N, p.Seed)
+	if err != nil {
+		fmt.Printf("Error generating scenarios: %s\n", err.Error())
+		os.Exit(1)
+	}
+	for _, s := range(scenarios) {
+		fmt.Printf("Bucket %d: (%d,%d) -> (%d,%d) optimal length %.1f\n", s.Bucket, s.Start.X, s.Start.Y, s.Goal.X, s.Goal.Y, s.OptimalLength)
+	}
+
+	err = SaveScenarios(scenarios, p.OutPath)
+	if err != nil {
+		fmt.Printf("Error writing scenarios file \"%s\": %s\n", p.OutPath, err.Error())
+		os.Exit(1)
+	}
+}
+
+func runGenerateMapMode(p PathyParameters) {
+	if p.Mode != GenMap {
+		panic("Assertion failed: unexpected mode")
+	}
+	g, err := GenerateMap(p.Generator, p.Width, p.Height, p.Param, p.Seed)
+	if err != nil {
+		fmt.Printf("Error generating map: %s\n", err.Error())
+		os.Exit(1)
+	}
+	err = SaveMap(g, p.OutPath)
+	if err != nil {
+		fmt.Printf("Error writing map file \"%s\": %s\n", p.OutPath, err.Error())
+		os.Exit(1)
+	}
+}
+
+func runComponentsMode(p PathyParameters) {
+	if p.Mode != Components {
+		panic("Assertion failed: unexpected mode")
+	}
+	loaded, err := LoadMap(p.InPath)
+	if err != nil {
+		fmt.Printf("Error reading file \"%s\": %s\n", p.InPath, err.Error())
+		os.Exit(1)
+	}
+	setGrid(loaded)
+
+	cellCounts := componentCellCounts()
+	fmt.Printf("%d connected component(s)\n", len(componentSizes))
+	for i, label := range(componentsBySize()) {
+		if i == 10 {
+			fmt.Printf("... and %d smaller component(s)\n", len(componentSizes)-i)
+			break
+		}
+		fmt.Printf("Component %d: %d node(s), %d open cell(s)\n", i+1, componentSizes[label], cellCounts[label])
+	}
+}
+
+func runStatsMode(p PathyParameters) {
+	if p.Mode != Stats {
+		panic("Assertion failed: unexpected mode")
+	}
+
+	// A scenarios file also gets statistics about its map
+	mapPath := p.InPath
+	var scenarios []Scenario
+	if strings.ToLower(filepath.Ext(p.InPath)) == ".scen" {
+		var err error
+		scenarios, err = LoadScenarios(p.InPath)
+		if err != nil {
+			fmt.Printf("Error loading scenarios file \"%s\": %s\n", p.InPath, err.Error())
+			os.Exit(1)
+		}
+		if len(scenarios) == 0 {
+			fmt.Printf("The scenarios file \"%s\" has no scenarios\n", p.InPath)
+			os.Exit(1)
+		}
+		mapPath = filepath.Join(filepath.Dir(p.InPath), scenarios[0].MapName)
+	}
+
+	loaded, err := LoadMap(mapPath)
+	if err != nil {
+		fmt.Printf("Error reading map file \"%s\": %s\n", mapPath, err.Error())
+		os.Exit(1)
+	}
+	setGrid(loaded)
+
+	s := ComputeMapStats()
+	fmt.Printf("Map %s\n", mapPath)
+	fmt.Printf("Dimensions: %dx%d\n", s.Width, s.Height)
+	fmt.Printf("Open cells: %d (%.1f%%)\n", s.OpenCells, 100*s.OpenRatio)
+	fmt.Printf("Connected components: %d, the largest has %d open cell(s)\n", s.ComponentCount, s.LargestComponent)
+	fmt.Printf("Avg clearance: %.2f cell(s), avg corridor width: %.2f cell(s)\n", s.AvgClearance, s.AvgCorridorWidth)
+	fmt.Printf("Corner nodes: %d\n", s.CornerNodes)
+
+	if scenarios == nil {
+		return
+	}
+
+	fmt.Printf("\nScenarios %s\n", p.InPath)
+	fmt.Printf("Count: %d\n", len(scenarios))
+
+	// Consecutive buckets with the same number of scenarios are grouped
+	bucketCounts := map[int]int{}
+	minBucket, maxBucket := scenarios[0].Bucket, scenarios[0].Bucket
+	maxLen := 0.0
+	for _, scenario := range(scenarios) {
+		bucketCounts[scenario.Bucket]++
+		minBucket = int(math.Min(float64(minBucket), float64(scenario.Bucket)))
+		maxBucket = int(math.Max(float64(maxBucket), float64(scenario.Bucket)))
+		maxLen    = math.Max(maxLen, scenario.OptimalLength)
+	}
+	fmt.Println("Bucket distribution:")
+	first := minBucket
+	for b := minBucket; b <= maxBucket; b++ {
+		if b < maxBucket && bucketCounts[b+1] == bucketCounts[first] {
+			continue
+		}
+		if first == b {
+			fmt.Printf("    bucket %d: %d scenario(s)\n", b, bucketCounts[b])
+		} else {
+			fmt.Printf("    buckets %d-%d: %d scenario(s) each\n", first, b, bucketCounts[b])
+		}
+		first = b+1
+	}
+
+	const bins = 10
+	binWidth := math.Max(maxLen / bins, 1e-9)
+	histogram := make([]int, bins)
+	maxCount  := 0
+	for _, scenario := range(scenarios) {
+		bin := int(scenario.OptimalLength / binWidth)
+		if bin >= bins {
+			bin = bins-1
+		}
+		histogram[bin]++
+		maxCount = int(math.Max(float64(maxCount), float64(histogram[bin])))
+	}
+	fmt.Println("Optimal length histogram:")
+	for i, count := range(histogram) {
+		bar := strings.Repeat("#", int(math.Ceil(40 * float64(count) / float64(maxCount))))
+		fmt.Printf("    %8.1f - %8.1f: %5d %s\n", float64(i)*binWidth, float64(i+1)*binWidth, count, bar)
+	}
+}
+
+/*
+ * Selects n scenarios that are evenly spread out in the file, or all of
+ * them if there are not more than n.
+ */
+func selectScenarios(scenarios []Scenario, n int) []Scenario {
+	selectedScenarios := []Scenario{}
+	var inc float64
+	if n >= len(scenarios) {
+		inc = 1
+		n   = len(scenarios)
+	} else {
+		inc = float64(len(scenarios)-1) / float64(n-1)
+	}
+	for i := 0.0; i < float64(len(scenarios)); i += inc {
+		index := int(i)
+		selectedScenarios = append(selectedScenarios, scenarios[index])
+	}
+	// Assertion
+	if len(selectedScenarios) != n {
+		panic("Assertion failed: unexpected number of selected scenarios")
+	}
+	return selectedScenarios
 }
 
 /*
@@ -304,28 +770,34 @@ func runMultipleMode(p PathyParameters) {
  * path length
  * average angle of turns (radians)
  * average runtime (ms)
+ * average heap memory allocated by the algorithm (MB)
+ * peak number of entries in the data structures of the algorithm
  */
-func testOneScenario(start, goal Node, algo func (start, goal Node) []Node, trials int) ([]Node, int, float64, float64, int) {
-	var path []Node
+func testOneScenario(start, goal Node, algo func (start, goal Node) []Point, trials int) ([]Point, int, float64, float64, int, float64, int) {
+	var path []Point
 
-	// Get path and average runtime
-	totalRuntime := 0.0
+	// Get path, average runtime and average allocated memory. Memory is
+	// measured outside of the timed part because reading it stops the
+	// program for a moment.
+	totalRuntime   := 0.0
+	totalAllocated := 0.0
+	var memBefore, memAfter runtime.MemStats
 	for i := 0; i < trials; i++ {
+		peakStoredNodes = 0
+		expansions      = 0
+		runtime.ReadMemStats(&memBefore)
 		before  := time.Now()
 		path     = algo(start, goal)
 		after   := time.Now()
 		elapsed := after.Sub(before)
 		totalRuntime += float64(elapsed.Milliseconds())
+		runtime.ReadMemStats(&memAfter)
+		totalAllocated += float64(memAfter.TotalAlloc - memBefore.TotalAlloc)
 	}
-	avgRuntime := int(math.Round(totalRuntime/float64(trials)))
+	avgRuntime   := int(math.Round(totalRuntime/float64(trials)))
+	avgAllocated := totalAllocated / float64(trials) / (1 << 20)
 
-	// Calculate path length
-	pathLen := 0.0
-	for i := 0; i < len(path)-1; i++ {
-		n1 := path[i]
-		n2 := path[i+1]
-		pathLen += StraightLineDist(n1, n2)
-	}
+	pathLen := PointPathLength(path)
 
 	// Calculate turn count and average angle of turns
 	turns    := 0
@@ -335,8 +807,8 @@ func testOneScenario(start, goal Node, algo func (start, goal Node) []Node, tria
 		n2 := path[i+1]
 		n3 := path[i+2]
 		// There are two vectors (n1,n2) and (n2,n3)
-		v1_x, v1_y := float64(n2.X - n1.X), float64(n2.Y - n1.Y)
-		v2_x, v2_y := float64(n3.X - n2.X), float64(n3.Y - n2.Y)
+		v1_x, v1_y := n2.X - n1.X, n2.Y - n1.Y
+		v2_x, v2_y := n3.X - n2.X, n3.Y - n2.Y
 		dot    := v1_x * v2_x + v1_y * v2_y
 		v1_len := math.Sqrt(v1_x * v1_x + v1_y * v1_y)
 		v2_len := math.Sqrt(v2_x * v2_x + v2_y * v2_y)
@@ -354,25 +826,44 @@ func testOneScenario(start, goal Node, algo func (start, goal Node) []Node, tria
 		avgAngle /= float64(turns)
 	}
 
-	return path, turns, pathLen, avgAngle, avgRuntime
+	return path, turns, pathLen, avgAngle, avgRuntime, avgAllocated, peakStoredNodes
 }
 
-func MustParsePathfindingFunction(algoName string) func(Node, Node) []Node {
-	switch strings.ToLower(algoName) {
-		case "dijkstra":
-			return Dijkstra
-		case "astar":
-			return AStar
-		case "astar-ps":
-			return AStarPs
-		case "thetastar":
-			return ThetaStar
-		// case "ap-thetastar":
-			// return true, ApThetaStar
+/*
+ * Runs the preprocessing of an algorithm and its heuristic on the current
+ * map, if they have any, so that it is not part of the runtime of the
+ * first query. Also warns about options and a heuristic that do not fit.
+ * Returns how long the preprocessing took.
+ */
+func preprocessAlgorithm(a Algorithm, mapPath string) time.Duration {
+	warnAboutOptions(a)
+	warnAboutHeuristic(a, connectivity)
+	steps := []func(string){}
+	if a.Preprocess != nil {
+		steps = append(steps, a.Preprocess)
+	}
+	if selectedHeuristic == "landmark" && a.supports("heuristic") && a.Name != "dstarlite" {
+		steps = append(steps, preprocessLandmarks)
 	}
-	fmt.Printf("Unknown algorithm \"%s\"\n", algoName)
-	os.Exit(1)
-	return func( Node, Node) []Node { return []Node{} }
+	if len(steps) == 0 {
+		return 0
+	}
+	before := time.Now()
+	for _, preprocess := range(steps) {
+		preprocess(mapPath)
+	}
+	elapsed := time.Since(before)
+	fmt.Printf("Preprocessing took %dms\n", elapsed.Milliseconds())
+	return elapsed
+}
+
+func MustParseFloat(arg string) float64 {
+	f, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		fmt.Printf("Non-float argument \"%s\"\n", arg)
+		os.Exit(1)
+	}
+	return f
 }
 
 func MustParseInt(arg string) int {
@@ -383,3 +874,375 @@ func MustParseInt(arg string) int {
 	}
 	return n
 }
+
+func runReplanMode(p PathyParameters) {
+	if p.Mode != ReplanChanges {
+		panic("Assertion failed: unexpected mode")
+	}
+	loaded, err := LoadMap(p.InPath)
+	if err != nil {
+		fmt.Printf("Error reading file \"%s\": %s\n", p.InPath, err.Error())
+		os.Exit(1)
+	}
+	// The landmarks would have to be selected again after every change
+	if selectedHeuristic == "landmark" {
+		fmt.Println("The landmark heuristic cannot be used when replanning, its distances change with the cells")
+		os.Exit(1)
+	}
+	setGrid(loaded)
+	warnAboutHeuristic(MustParseAlgorithm("dstarlite"), connectivity)
+	changes, err := LoadChanges(p.InPaths[0])
+	if err != nil {
+		fmt.Printf("Error reading changes file \"%s\": %s\n", p.InPaths[0], err.Error())
+		os.Exit(1)
+	}
+
+	// Changed cells go up to the size of the map, nodes up to the largest
+	// node coordinates, which are one more in the corner model
+	maxX, maxY := maxNodeCoordinates()
+	nodeOnMap := func(x, y int) bool {
+		return x >= 0 && y >= 0 && x <= maxX && y <= maxY
+	}
+	if !nodeOnMap(p.StartX, p.StartY) || !nodeOnMap(p.GoalX, p.GoalY) {
+		fmt.Printf("The start (%d,%d) or the goal (%d,%d) is outside the map\n", p.StartX, p.StartY, p.GoalX, p.GoalY)
+		os.Exit(1)
+	}
+
+	start   := NewNode(p.StartX, p.StartY)
+	goal    := NewNode(p.GoalX,  p.GoalY)
+	before  := time.Now()
+	planner := NewDStarLite(start, goal)
+	path    := planner.Replan()
+	fmt.Printf("Initial plan: length %.1f, runtime %.3fms, %d expansions\n", PathLength(path), float64(time.Since(before).Microseconds())/1000, planner.Expansions)
+
+	// Cells are changed right away, but D* Lite only hears about them
+	// when replanning so that the update is part of its runtime
+	changedCells := [][2]int{}
+	totalIncremental := time.Duration(0)
+	totalFull        := time.Duration(0)
+	mismatches       := 0
+	replans          := 0
+	for _, change := range(changes) {
+		cellOnMap := change.X >= 0 && change.Y >= 0 && change.X < len(grid[0]) && change.Y < len(grid)
+		if (change.Kind == MoveStart && !nodeOnMap(change.X, change.Y)) || ((change.Kind == BlockCell || change.Kind == OpenCell) && !cellOnMap) {
+			fmt.Printf("Change (%d,%d) is outside the map\n", change.X, change.Y)
+			os.Exit(1)
+		}
+		switch change.Kind {
+			case BlockCell, OpenCell:
+				SetCellBlocked(change.X, change.Y, change.Kind == BlockCell)
+				changedCells = append(changedCells, [2]int{change.X, change.Y})
+			case MoveStart:
+				start = NewNode(change.X, change.Y)
+				planner.MoveStart(start)
+			case Replan:
+				replans++
+				before = time.Now()
+				for _, cell := range(changedCells) {
+					planner.UpdateCell(cell[0], cell[1])
+				}
+				path = planner.Replan()
+				incremental := time.Since(before)
+				changedCells = [][2]int{}
+
+				before = time.Now()
+				fullPath := AStar(start, goal)
+				full := time.Since(before)
+
+				totalIncremental += incremental
+				totalFull        += full
+				fmt.Printf("Replan %d from (%d,%d): D* Lite length %.1f in %.3fms (%d expansions), A* length %.1f in %.3fms\n",
+					replans, start.X, start.Y, PathLength(path), float64(incremental.Microseconds())/1000, planner.Expansions,
+					PathLength(fullPath), float64(full.Microseconds())/1000)
+				if math.Abs(PathLength(path) - PathLength(fullPath)) > 1e-6 || (len(path) == 0) != (len(fullPath) == 0) {
+					fmt.Println("    The path lengths differ!")
+					mismatches++
+				}
+		}
+	}
+	fmt.Printf("\nTotal replanning runtime: D* Lite %.3fms, A* %.3fms\n", float64(totalIncremental.Microseconds())/1000, float64(totalFull.Microseconds())/1000)
+	if mismatches > 0 {
+		fmt.Printf("%d replan(s) found paths of different lengths\n", mismatches)
+		os.Exit(1)
+	}
+}
+
+/*
+ * Runs every algorithm on the same scenarios and prints a table of their
+ * averages. The length ratio is the length divided by the shortest length
+ * found by an octile-optimal algorithm. Octile-optimal algorithms must all
+ * find that length and every path must be valid, otherwise the scenario is
+ * listed as a failure.
+ */
+func runCompareMode(p PathyParameters) {
+	if p.Mode != Compare {
+		panic("Assertion failed: unexpected mode")
+	}
+	scenarios, err := LoadScenarios(p.InPath)
+	if err != nil {
+		fmt.Printf("Error loading scenarios file \"%s\": %s\n", p.InPath, err.Error())
+		os.Exit(1)
+	}
+	if len(scenarios) == 0 {
+		fmt.Printf("The scenarios file \"%s\" has no scenarios\n", p.InPath)
+		os.Exit(1)
+	}
+	mapPath := filepath.Join(filepath.Dir(p.InPath), scenarios[0].MapName)
+	loaded, err := LoadMap(mapPath)
+	if err != nil {
+		fmt.Printf("Error reading map file \"%s\": %s\n", mapPath, err.Error())
+		os.Exit(1)
+	}
+	loaded = ApplyMorphology(loaded, morphologySteps)
+	selectedScenarios := selectScenarios(scenarios, p.N)
+
+	// lengths[i][j] is the length of scenario j with algorithm i
+	failures      := []string{}
+	lengths       := make([][]float64, len(p.Algos))
+	preprocessing := make([]time.Duration, len(p.Algos))
+	runtimes      := make([]int, len(p.Algos))
+	allocated     := make([]float64, len(p.Algos))
+	stored        := make([]int, len(p.Algos))
+	for i, a := range(p.Algos) {
+		fmt.Printf("Running %s\n", a.Name)
+		setGrid(loaded) // Forget what the previous algorithm preprocessed
+		preprocessing[i] = preprocessAlgorithm(a, mapPath)
+		for _, s := range(selectedScenarios) {
+			path, _, pathLen, _, avgRuntime, avgAllocated, peak := testOneScenario(s.Start, s.Goal, a.Find, p.Trials)
+			if err := ValidatePath(path, s.Start, s.Goal, a.Path); err != nil {
+				failures = append(failures, fmt.Sprintf("%s: (%d,%d) -> (%d,%d) has an invalid path: %s", a.Name, s.Start.X, s.Start.Y, s.Goal.X, s.Goal.Y, err.Error()))
+			}
+			lengths[i]    = append(lengths[i], pathLen)
+			runtimes[i]  += avgRuntime
+			allocated[i] += avgAllocated
+			stored[i]    += peak
+		}
+		reportHPAFallbacks()
+		reportSubgoalFallbacks()
+	}
+
+	const epsilon = 1e-6
+	shortest := make([]float64, len(selectedScenarios))
+	for j := range(selectedScenarios) {
+		shortest[j] = math.Inf(1)
+		for i, a := range(p.Algos) {
+			if a.Optimality == OctileOptimal {
+				shortest[j] = math.Min(shortest[j], lengths[i][j])
+			}
+		}
+	}
+	n := len(selectedScenarios)
+	fmt.Printf("\n%-12s %-15s %14s %10s %12s %8s %13s %10s\n", "Algorithm", "Class", "Preprocessing", "Runtime", "Length", "Ratio", "Stored nodes", "Allocated")
+	for i, a := range(p.Algos) {
+		sumLength, sumRatio, ratios := 0.0, 0.0, 0
+		for j, s := range(selectedScenarios) {
+			sumLength += lengths[i][j]
+			if !math.IsInf(shortest[j], 1) && shortest[j] > 0 {
+				sumRatio += lengths[i][j] / shortest[j]
+				ratios++
+			}
+			if a.Optimality == OctileOptimal && math.Abs(lengths[i][j] - shortest[j]) > epsilon {
+				failures = append(failures, fmt.Sprintf("%s: (%d,%d) -> (%d,%d) has length %f, the shortest octile-optimal length is %f",
+					a.Name, s.Start.X, s.Start.Y, s.Goal.X, s.Goal.Y, lengths[i][j], shortest[j]))
+			}
+		}
+		ratio := "-"
+		if ratios > 0 {
+			ratio = fmt.Sprintf("%.3f", sumRatio / float64(ratios))
+		}
+		fmt.Printf("%-12s %-15s %12dms %8dms %12.2f %8s %13d %8.2fMB\n", a.Name, a.Optimality, preprocessing[i].Milliseconds(),
+			runtimes[i] / n, sumLength / float64(n), ratio, stored[i] / n, allocated[i] / float64(n))
+	}
+
+	for _, failure := range(failures) {
+		fmt.Println(failure)
+	}
+	if len(failures) > 0 {
+		os.Exit(1)
+	}
+}
+
+/*
+ * Selects the landmarks and saves them. The time and the memory of the
+ * distance tables are printed when the number of landmarks reaches a
+ * power of two and at the end, to show what more landmarks cost.
+ */
+func runLandmarksMode(p PathyParameters) {
+	if p.Mode != SelectLandmarksMode {
+		panic("Assertion failed: unexpected mode")
+	}
+	loaded, err := LoadMap(p.InPath)
+	if err != nil {
+		fmt.Printf("Error reading file \"%s\": %s\n", p.InPath, err.Error())
+		os.Exit(1)
+	}
+	loaded = ApplyMorphology(loaded, morphologySteps)
+	setGrid(loaded)
+
+	report := func(l *Landmarks, elapsed time.Duration) {
+		k := len(l.Nodes)
+		if k & (k-1) == 0 || k == p.N {
+			fmt.Printf("%d landmark(s): selected in %dms, tables take %.2fMB\n", k, elapsed.Milliseconds(), float64(l.tableSize())/1e6)
+		}
+	}
+	l, err := SelectLandmarks(p.N, p.Strategy, p.Seed, report)
+	if err != nil {
+		fmt.Printf("Error selecting landmarks: %s\n", err.Error())
+		os.Exit(1)
+	}
+	err = SaveLandmarks(l, p.OutPath)
+	if err != nil {
+		fmt.Printf("Error writing landmarks file \"%s\": %s\n", p.OutPath, err.Error())
+		os.Exit(1)
+	}
+}
+
+/*
+ * Runs an algorithm on the same scenarios with every tie-breaking policy
+ * and prints a table of their averages. The policies only change which
+ * of the shortest paths an octile-optimal algorithm finds, so their
+ * lengths must agree, otherwise the scenario is listed as a failure.
+ */
+func runTieBreakingMode(p PathyParameters) {
+	if p.Mode != CompareTieBreaking {
+		panic("Assertion failed: unexpected mode")
+	}
+	scenarios, err := LoadScenarios(p.InPath)
+	if err != nil {
+		fmt.Printf("Error loading scenarios file \"%s\": %s\n", p.InPath, err.Error())
+		os.Exit(1)
+	}
+	if len(scenarios) == 0 {
+		fmt.Printf("The scenarios file \"%s\" has no scenarios\n", p.InPath)
+		os.Exit(1)
+	}
+	mapPath := filepath.Join(filepath.Dir(p.InPath), scenarios[0].MapName)
+	loaded, err := LoadMap(mapPath)
+	if err != nil {
+		fmt.Printf("Error reading map file \"%s\": %s\n", mapPath, err.Error())
+		os.Exit(1)
+	}
+	loaded = ApplyMorphology(loaded, morphologySteps)
+	setGrid(loaded)
+	preprocessAlgorithm(p.Algo, mapPath)
+	selectedScenarios := selectScenarios(scenarios, p.N)
+	n := len(selectedScenarios)
+
+	const epsilon = 1e-6
+	failures := []string{}
+	lengths  := make([]float64, n) // The lengths with the first policy
+	fmt.Printf("\n%-12s %12s %10s %12s %13s\n", "Tie-breaking", "Expansions", "Runtime", "Length", "Stored nodes")
+	for i := range(tieBreakingNames) {
+		tieBreaking = TieBreaking(i)
+		sumExpansions, sumRuntime, sumLength, sumStored := 0, 0, 0.0, 0
+		for j, s := range(selectedScenarios) {
+			_, _, pathLen, _, avgRuntime, _, peak := testOneScenario(s.Start, s.Goal, p.Algo.Find, p.Trials)
+			sumExpansions += expansions
+			sumRuntime    += avgRuntime
+			sumLength     += pathLen
+			sumStored     += peak
+			if i == 0 {
+				lengths[j] = pathLen
+			} else if p.Algo.Optimality == OctileOptimal && math.Abs(pathLen - lengths[j]) > epsilon {
+				failures = append(failures, fmt.Sprintf("%s: (%d,%d) -> (%d,%d) has length %f, but %f with %s",
+					tieBreaking, s.Start.X, s.Start.Y, s.Goal.X, s.Goal.Y, pathLen, lengths[j], TieBreaking(0)))
+			}
+		}
+		fmt.Printf("%-12s %12d %8dms %12.2f %13d\n", tieBreaking, sumExpansions / n, sumRuntime / n, sumLength / float64(n), sumStored / n)
+	}
+
+	for _, failure := range(failures) {
+		fmt.Println(failure)
+	}
+	if len(failures) > 0 {
+		os.Exit(1)
+	}
+}
+
+/*
+ * Applies the steps to a map and saves the result, with the number of
+ * cells that the steps blocked and opened if none of them moves the cells.
+ * With a scenarios file, the scenarios move with the map and get
+ * their optimal lengths on the new map, and those whose start and goal
+ * are no longer connected are dropped.
+ */
+func runTransformMode(p PathyParameters) {
+	if p.Mode != Transform {
+		panic("Assertion failed: unexpected mode")
+	}
+	loaded, err := LoadMap(p.InPath)
+	if err != nil {
+		fmt.Printf("Error reading file \"%s\": %s\n", p.InPath, err.Error())
+		os.Exit(1)
+	}
+	scenarios := []Scenario{}
+	if len(p.InPaths) > 0 {
+		scenarios, err = LoadScenarios(p.InPaths[0])
+		if err != nil {
+			fmt.Printf("Error reading file \"%s\": %s\n", p.InPaths[0], err.Error())
+			os.Exit(1)
+		}
+		for _, s := range(scenarios) {
+			if s.Start.X >= len(loaded[0]) || s.Start.Y >= len(loaded) || s.Goal.X >= len(loaded[0]) || s.Goal.Y >= len(loaded) {
+				fmt.Printf("The scenario (%d,%d) -> (%d,%d) does not fit in the %dx%d map\n", s.Start.X, s.Start.Y, s.Goal.X, s.Goal.Y, len(loaded[0]), len(loaded))
+				os.Exit(1)
+			}
+		}
+	}
+	// --morphology applies right after loading, like in the other modes
+	steps := append(append([]TransformStep{}, morphologySteps...), p.Steps...)
+	transformed, moved, err := ApplyTransforms(loaded, scenarios, steps)
+	if err != nil {
+		fmt.Printf("Error transforming the map: %s\n", err.Error())
+		os.Exit(1)
+	}
+	geometric := false
+	for _, step := range(steps) {
+		for _, name := range(geometryNames) {
+			geometric = geometric || step.Name == name
+		}
+	}
+	if geometric {
+		// The cells moved, so only their numbers compare
+		blocked := 0
+		for y := range(transformed) {
+			for x := range(transformed[y]) {
+				if transformed[y][x] {
+					blocked++
+				}
+			}
+		}
+		fmt.Printf("The map is now %dx%d with %d blocked cell(s)\n", len(transformed[0]), len(transformed), blocked)
+	} else {
+		blocked, opened := 0, 0
+		for y := range(loaded) {
+			for x := range(loaded[y]) {
+				if transformed[y][x] && !loaded[y][x] {
+					blocked++
+				} else if !transformed[y][x] && loaded[y][x] {
+					opened++
+				}
+			}
+		}
+		fmt.Printf("Blocked %d cell(s) and opened %d cell(s)\n", blocked, opened)
+	}
+	err = SaveMap(transformed, p.OutPath)
+	if err != nil {
+		fmt.Printf("Error writing map file \"%s\": %s\n", p.OutPath, err.Error())
+		os.Exit(1)
+	}
+	if len(p.InPaths) == 0 {
+		return
+	}
+
+	kept := RecomputeOptimalLengths(transformed, filepath.Base(p.OutPath), moved)
+	if len(kept) < len(moved) {
+		fmt.Printf("Dropped %d scenario(s) whose start and goal are no longer connected\n", len(moved) - len(kept))
+	}
+	fmt.Printf("Wrote %d scenario(s)\n", len(kept))
+	err = SaveScenarios(kept, p.InPaths[1])
+	if err != nil {
+		fmt.Printf("Error writing scenarios file \"%s\": %s\n", p.InPaths[1], err.Error())
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"container/heap"
+	"errors"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+/*
+ * Samples n random scenarios on the current grid. Start and goal are
+ * open cells that are connected to each other with the moves of the
+ * movingai files, so a path always exists. The optimal length and the
+ * bucket follow the movingai files too, whatever the options are: see
+ * movingaiLength. The bucket is the length divided by 4. The scenarios
+ * are sorted by length.
+ * Returns a non-nil error if the map has too few connected open cells.
+ */
+func GenerateScenarios(mapName string, n int, seed int64) ([]Scenario, error) {
+	rng := rand.New(rand.NewSource(seed))
+	scenarios := []Scenario{}
+
+	// Components with a single open cell cannot hold a scenario
+	cellsByComponent := movingaiComponents(grid)
+	component := map[Node]int{}
+	openCells := []Node{}
+	for c, cells := range(cellsByComponent) {
+		for _, cell := range(cells) {
+			component[cell] = c
+		}
+		if len(cells) >= 2 {
+			openCells = append(openCells, cells...)
+		}
+	}
+	if len(openCells) < 2 {
+		return scenarios, errors.New("The map has no two connected open cells")
+	}
+
+	for len(scenarios) < n {
+		start := openCells[rng.Intn(len(openCells))]
+		candidates := cellsByComponent[component[start]]
+		goal := candidates[rng.Intn(len(candidates))]
+		if goal == start {
+			continue
+		}
+
+		scenario := Scenario{}
+		scenario.MapName = mapName
+		scenario.Width   = len(grid[0])
+		scenario.Height  = len(grid)
+		scenario.Start   = start
+		scenario.Goal    = goal
+		scenario.OptimalLength, _ = movingaiLength(grid, start, goal)
+		scenario.Bucket  = int(scenario.OptimalLength / 4)
+		scenarios = append(scenarios, scenario)
+	}
+
+	sort.SliceStable(scenarios, func(i, j int) bool {
+		return scenarios[i].OptimalLength < scenarios[j].OptimalLength
+	})
+	return scenarios, nil
+}
+
+/*
+ * The moves of the movingai files from a cell: to the 8 neighbouring open
+ * cells, where a diagonal move also needs both cells beside it open, so
+ * that it cuts no corners.
+ */
+func movingaiNeighbours(g [][]bool, n Node) []Node {
+	open := func(x, y int) bool {
+		return movingaiOpen(g, NewNode(x, y))
+	}
+	neighbours := []Node{}
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -1; dx <= 1; dx++ {
+			if (dx != 0 || dy != 0) && open(n.X+dx, n.Y+dy) && open(n.X+dx, n.Y) && open(n.X, n.Y+dy) {
+				neighbours = append(neighbours, NewNode(n.X+dx, n.Y+dy))
+			}
+		}
+	}
+	return neighbours
+}
+
+func movingaiOpen(g [][]bool, n Node) bool {
+	return n.X >= 0 && n.Y >= 0 && n.Y < len(g) && n.X < len(g[0]) && !g[n.Y][n.X]
+}
+
+// The open cells of a map grouped by the components of the movingai
+// moves, in row order
+func movingaiComponents(g [][]bool) [][]Node {
+	seen := map[Node]bool{}
+	cellsByComponent := [][]Node{}
+	for y := 0; y < len(g); y++ {
+		for x := 0; x < len(g[0]); x++ {
+			if g[y][x] || seen[NewNode(x, y)] {
+				continue
+			}
+			seen[NewNode(x, y)] = true
+			cells := []Node{NewNode(x, y)}
+			for i := 0; i < len(cells); i++ {
+				for _, n := range(movingaiNeighbours(g, cells[i])) {
+					if !seen[n] {
+						seen[n] = true
+						cells = append(cells, n)
+					}
+				}
+			}
+			// Keep the result reproducible
+			sort.Slice(cells, func(i, j int) bool {
+				if cells[i].Y != cells[j].Y {
+					return cells[i].Y < cells[j].Y
+				}
+				return cells[i].X < cells[j].X
+			})
+			cellsByComponent = append(cellsByComponent, cells)
+		}
+	}
+	return cellsByComponent
+}
+
+/*
+ * The optimal length between two cells like in the movingai files: a
+ * one-cell agent moves between cell centers with movingaiNeighbours, and
+ * diagonal moves cost sqrt(2). It does not depend on --model,
+ * --connectivity, --agent-size or --costs, so scenario files stay
+ * comparable with published benchmarks. A* with a binary heap, which is
+ * fast enough for the thousands of scenarios of a large map. Returns
+ * false if there is no path.
+ */
+func movingaiLength(g [][]bool, start, goal Node) (float64, bool) {
+	if !movingaiOpen(g, start) || !movingaiOpen(g, goal) {
+		return 0, false
+	}
+	octile := func(n Node) float64 {
+		dx, dy := math.Abs(float64(n.X - goal.X)), math.Abs(float64(n.Y - goal.Y))
+		return math.Max(dx, dy) + (math.Sqrt2 - 1) * math.Min(dx, dy)
+	}
+	dist  := map[Node]float64{start: 0}
+	queue := nodeQueue{{start, octile(start), 0}}
+	for len(queue) > 0 {
+		entry := heap.Pop(&queue).(queuedNode)
+		n := entry.node
+		if entry.g > dist[n] {
+			continue // Outdated entry
+		}
+		if n == goal {
+			return entry.g, true
+		}
+		for _, neighbour := range(movingaiNeighbours(g, n)) {
+			d := entry.g + 1
+			if neighbour.X != n.X && neighbour.Y != n.Y {
+				d = entry.g + math.Sqrt2
+			}
+			if old, found := dist[neighbour]; !found || d < old {
+				dist[neighbour] = d
+				heap.Push(&queue, queuedNode{neighbour, d + octile(neighbour), d})
+			}
+		}
+	}
+	return 0, false
+}
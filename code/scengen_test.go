@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+/*
+ * Generates scenarios on random maps of up to 16x16 cells in every node
+ * model and connectivity. The scenarios must be the same in all of them,
+ * with the lengths of A* between cell centers without corner cutting,
+ * like the movingai files.
+ */
+func TestGenerateScenarios(t *testing.T) {
+	keepGlobals(t)
+	const epsilon = 1e-6
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		g := randomGrid(random, 16, 16, 0.5)
+		nodeModel, connectivity = CellCenters, EightConnectedNoCornerCutting
+		setGrid(g)
+		reference, err := GenerateScenarios("test", 5, int64(i))
+		if err != nil {
+			continue // Too few open cells
+		}
+		for _, s := range(reference) {
+			length := PathLength(AStar(s.Start, s.Goal))
+			if math.Abs(length - s.OptimalLength) > epsilon {
+				t.Errorf("map %d: (%d,%d) -> (%d,%d) has length %g, A* finds %g\n%s",
+					i, s.Start.X, s.Start.Y, s.Goal.X, s.Goal.Y, s.OptimalLength, length, gridString(g))
+			}
+		}
+		for _, model := range(allNodeModels) {
+			for _, c := range(allConnectivities) {
+				nodeModel, connectivity = model, c
+				setGrid(g)
+				scenarios, _ := GenerateScenarios("test", 5, int64(i))
+				if fmt.Sprint(scenarios) != fmt.Sprint(reference) {
+					t.Errorf("map %d, %s model, %s-connectivity: the scenarios depend on the options\n%s",
+						i, nodeModelName(model), connectivityName(c), gridString(g))
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,548 @@
+package main
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+/*
+ * Post-processors that shorten or smooth the path of any algorithm. They
+ * are chained with --smooth, and every step keeps the path traversable:
+ * a straight piece is only used where it passes pointsVisible.
+ */
+var smoothingNames = []string{"greedy", "greedy-repeat", "string-pull", "catmull-rom"}
+
+var smoothingSteps []string // The steps of --smooth in order
+
+// Samples per unit of length on the curves of catmull-rom
+const curveSamplesPerUnit = 4
+
+func MustParseSmoothing(value string) []string {
+	steps := []string{}
+	for _, step := range(strings.Split(strings.ToLower(value), ",")) {
+		known := false
+		for _, name := range(smoothingNames) {
+			known = known || step == name
+		}
+		if !known {
+			fmt.Printf("Unknown smoothing \"%s\", accepted smoothings are \"%s\"\n", step, strings.Join(smoothingNames, "\", \""))
+			os.Exit(1)
+		}
+		steps = append(steps, step)
+	}
+	return steps
+}
+
+/*
+ * Runs the smoothing steps after the algorithm, so that their time is part
+ * of its runtime and the metrics are those of the smoothed path. A smoothed
+ * path is in no optimality class, so the algorithm becomes suboptimal.
+ */
+func withSmoothing(a Algorithm) Algorithm {
+	if len(smoothingSteps) == 0 || a.Find == nil {
+		return a
+	}
+	find := a.Find
+	a.Find = func(start, goal Node) []Point {
+		return SmoothPath(find(start, goal))
+	}
+	a.Optimality = Suboptimal
+	if a.Path == Moves {
+		a.Path = Lines
+	}
+	return a
+}
+
+func SmoothPath(path []Point) []Point {
+	for _, step := range(smoothingSteps) {
+		switch step {
+			case "greedy":
+				path = greedySmoothing(path)
+			case "greedy-repeat":
+				for {
+					smoothed := greedySmoothing(path)
+					if len(smoothed) == len(path) {
+						break
+					}
+					path = smoothed
+				}
+			case "string-pull":
+				path = pullString(path)
+			case "catmull-rom":
+				path = catmullRomSmoothing(path)
+			default:
+				panic("Assertion failed: unknown smoothing")
+		}
+	}
+	return path
+}
+
+/*
+ * One pass of the post-smoothing of astar-ps: a point is skipped when the
+ * last point kept can see the point after it.
+ */
+func greedySmoothing(path []Point) []Point {
+	if len(path) < 3 {
+		return path
+	}
+	smoothPath := []Point{path[0]}
+	for i := 1; i < len(path)-1; i++ {
+		if !pointsVisible(smoothPath[len(smoothPath)-1], path[i+1], isOpen) {
+			smoothPath = append(smoothPath, path[i])
+		}
+	}
+	return append(smoothPath, path[len(path)-1])
+}
+
+/*
+ * The shortest path that stays in the corridor of open cells that the path
+ * touches. Such a path only turns at the corners of the corridor, so the
+ * candidates are the corners where it meets blocked or other cells, and
+ * the points of the path, which keeps the path itself possible. Without
+ * corner cutting, or between cell centers, a line may not touch those
+ * corners, so every corner and center of the corridor cells is a
+ * candidate instead. A* over the candidates, which all see each other if
+ * the line between them stays in the corridor, finds the shortest way.
+ */
+func pullString(path []Point) []Point {
+	if len(path) < 3 {
+		return path
+	}
+	corridor := corridorCells(path)
+
+	// A table over the box around the corridor is much faster than the map
+	// for the many line of sight checks
+	if len(corridor) == 0 {
+		return path
+	}
+	minX, minY := int(path[0].X), int(path[0].Y)
+	maxX, maxY := minX, minY
+	for cell := range(corridor) {
+		if cell[0] < minX {
+			minX = cell[0]
+		}
+		if cell[0] > maxX {
+			maxX = cell[0]
+		}
+		if cell[1] < minY {
+			minY = cell[1]
+		}
+		if cell[1] > maxY {
+			maxY = cell[1]
+		}
+	}
+	width := maxX - minX + 1
+	table := make([]bool, width * (maxY - minY + 1))
+	for cell := range(corridor) {
+		table[(cell[1] - minY) * width + cell[0] - minX] = true
+	}
+	inCorridor := func(x, y int) bool {
+		return x >= minX && x <= maxX && y >= minY && y <= maxY && table[(y - minY) * width + x - minX]
+	}
+
+	// The number of steps between neighbouring corridor cells from the
+	// start. A line goes through at most dx+dy+3 cells, all in the
+	// corridor, so the steps of two points that see each other differ by
+	// no more than that and the cells around the points, which rules out
+	// most pairs of a winding corridor without a line of sight check.
+	steps := make([]int, len(table))
+	for i := range(steps) {
+		steps[i] = -1
+	}
+	queue := [][2]int{}
+	for _, cell := range(cellsAround(path[0])) {
+		if inCorridor(cell[0], cell[1]) {
+			steps[(cell[1] - minY) * width + cell[0] - minX] = 0
+			queue = append(queue, cell)
+		}
+	}
+	for len(queue) > 0 {
+		cell := queue[0]
+		queue = queue[1:]
+		for _, d := range([][2]int{{-1,-1}, {0,-1}, {1,-1}, {-1,0}, {1,0}, {-1,1}, {0,1}, {1,1}}) {
+			x, y := cell[0] + d[0], cell[1] + d[1]
+			if inCorridor(x, y) && steps[(y - minY) * width + x - minX] == -1 {
+				steps[(y - minY) * width + x - minX] = steps[(cell[1] - minY) * width + cell[0] - minX] + 1
+				queue = append(queue, [2]int{x, y})
+			}
+		}
+	}
+	// Agents check the cells around the grid lines that they follow with
+	// agentFitsAtCorner instead of the corridor
+	agentLine := func(dx, dy float64) bool {
+		return agentSize > 0 && nodeModel == GridCorners && (dx == 0 || dy == 0)
+	}
+	// The fewest steps of the corridor cells around a point, -1 if there
+	// are none or they were not reached
+	pointSteps := func(p Point) int {
+		fewest := -1
+		for _, cell := range(cellsAround(p)) {
+			if inCorridor(cell[0], cell[1]) {
+				n := steps[(cell[1] - minY) * width + cell[0] - minX]
+				if n >= 0 && (fewest == -1 || n < fewest) {
+					fewest = n
+				}
+			}
+		}
+		return fewest
+	}
+
+	// Whether lines may touch any corner of the corridor, as with
+	// canTouchCorner, or only follow the grid lines
+	touchesCorners := connectivity == FourConnected ||
+	                  (connectivity == EightConnected && nodeModel == GridCorners)
+
+	// The corners of the corridor cells where one cell around is outside
+	// the corridor, or two cells diagonally opposite
+	candidates := append([]Point{}, path...)
+	blockedSide := make([]float64, len(path))
+	seen := map[Point]bool{}
+	for _, p := range(path) {
+		seen[p] = true
+	}
+	for cell := range(corridor) {
+		if !touchesCorners {
+			x, y := float64(cell[0]), float64(cell[1])
+			for _, p := range([]Point{{x, y}, {x+1, y}, {x, y+1}, {x+1, y+1}, {x+0.5, y+0.5}}) {
+				if !seen[p] {
+					seen[p] = true
+					candidates  = append(candidates, p)
+					blockedSide = append(blockedSide, 0)
+				}
+			}
+			continue
+		}
+		for _, corner := range([][2]int{{0,0}, {1,0}, {0,1}, {1,1}}) {
+			x, y := cell[0] + corner[0], cell[1] + corner[1]
+			nw, ne := inCorridor(x-1, y-1), inCorridor(x, y-1)
+			sw, se := inCorridor(x-1, y),   inCorridor(x, y)
+			outside := 0
+			for _, in := range([]bool{nw, ne, sw, se}) {
+				if !in {
+					outside++
+				}
+			}
+			p := Point{float64(x), float64(y)}
+			if (outside == 1 || (outside == 2 && nw == se)) && !seen[p] {
+				// Lines into the quadrant opposite a single cell outside
+				// are never part of a shortest path, because they would
+				// not bend around that cell
+				side := 0.0
+				if outside == 1 && (!nw || !se) {
+					side = 1
+				} else if outside == 1 {
+					side = -1
+				}
+				seen[p] = true
+				candidates  = append(candidates, p)
+				blockedSide = append(blockedSide, side)
+			}
+		}
+	}
+
+	// The consecutive points of the path are connected even if
+	// pointsVisible is too strict for them
+	goalIndex := len(path)-1
+	pathEdge := func(u, v int) bool {
+		return u < len(path) && v < len(path) && (v == u+1 || u == v+1)
+	}
+	// No path through a candidate that is longer than the path itself
+	// needs to be looked at
+	bound  := PointPathLength(path) + 1e-9
+	g      := make([]float64, len(candidates))
+	h      := make([]float64, len(candidates))
+	parent := make([]int, len(candidates))
+	closed := make([]bool, len(candidates))
+	for i := range(g) {
+		g[i] = math.Inf(1)
+		h[i] = PointDist(candidates[i], path[goalIndex])
+	}
+	g[0] = 0
+	candidateSteps := make([]int, len(candidates))
+	for i, p := range(candidates) {
+		candidateSteps[i] = pointSteps(p)
+	}
+	// The queued nodes hold the index of a candidate as X
+	openCandidates := nodeQueue{{NewNode(0, 0), h[0], 0}}
+	for {
+		if len(openCandidates) == 0 {
+			return path // Not expected, the path itself connects the goal
+		}
+		u := heap.Pop(&openCandidates).(queuedNode).node.X
+		if closed[u] {
+			continue // Outdated entry
+		}
+		if u == goalIndex {
+			break
+		}
+		closed[u] = true
+		for v := range(candidates) {
+			if closed[v] {
+				continue
+			}
+			dx, dy := candidates[v].X - candidates[u].X, candidates[v].Y - candidates[u].Y
+			if blockedSide[u]*dx*dy > 0 || blockedSide[v]*dx*dy > 0 {
+				continue
+			}
+			if candidateSteps[u] >= 0 && candidateSteps[v] >= 0 && !pathEdge(u, v) && !agentLine(dx, dy) &&
+			   math.Abs(float64(candidateSteps[u] - candidateSteps[v])) > math.Abs(dx) + math.Abs(dy) + 4 {
+				continue
+			}
+			d := g[u] + math.Hypot(dx, dy)
+			if d >= g[v] || d + h[v] > bound {
+				continue
+			}
+			if pathEdge(u, v) || pointsVisible(candidates[u], candidates[v], inCorridor) {
+				g[v]      = d
+				parent[v] = u
+				heap.Push(&openCandidates, queuedNode{NewNode(v, 0), d + h[v], d})
+			}
+		}
+	}
+	pulled := []Point{}
+	for i := goalIndex; i != 0; i = parent[i] {
+		pulled = append([]Point{candidates[i]}, pulled...)
+	}
+	return append([]Point{path[0]}, pulled...)
+}
+
+// The cells whose squares contain a point, on their border or inside
+func cellsAround(p Point) [][2]int {
+	xs, ys := []int{int(math.Floor(p.X))}, []int{int(math.Floor(p.Y))}
+	if p.X == math.Floor(p.X) {
+		xs = append(xs, xs[0]-1)
+	}
+	if p.Y == math.Floor(p.Y) {
+		ys = append(ys, ys[0]-1)
+	}
+	cells := [][2]int{}
+	for _, x := range(xs) {
+		for _, y := range(ys) {
+			cells = append(cells, [2]int{x, y})
+		}
+	}
+	return cells
+}
+
+// The open cells that the lines of a path touch
+func corridorCells(path []Point) map[[2]int]bool {
+	corridor := map[[2]int]bool{}
+	for i := 0; i < len(path)-1; i++ {
+		for _, cell := range(cellsTouched(path[i], path[i+1])) {
+			if isOpen(cell[0], cell[1]) {
+				corridor[cell] = true
+			}
+		}
+	}
+	return corridor
+}
+
+/*
+ * Replaces the straight pieces with a centripetal Catmull-Rom spline
+ * through the points of the path, which turns smoothly and does not loop.
+ * The spline between two points is only used if every line between its
+ * samples is clear, otherwise the piece stays straight.
+ */
+func catmullRomSmoothing(path []Point) []Point {
+	points := []Point{}
+	for _, p := range(path) {
+		if len(points) == 0 || points[len(points)-1] != p {
+			points = append(points, p)
+		}
+	}
+	if len(points) < 3 {
+		return points
+	}
+	// The first and last pieces continue straight beyond the ends
+	n := len(points)
+	first := Point{2*points[0].X - points[1].X, 2*points[0].Y - points[1].Y}
+	last  := Point{2*points[n-1].X - points[n-2].X, 2*points[n-1].Y - points[n-2].Y}
+	controls := append(append([]Point{first}, points...), last)
+
+	smoothPath := []Point{points[0]}
+	for i := 1; i < len(controls)-2; i++ {
+		p0, p1, p2, p3 := controls[i-1], controls[i], controls[i+1], controls[i+2]
+		samples := int(math.Max(2, math.Ceil(PointDist(p1, p2) * curveSamplesPerUnit)))
+		curve   := []Point{}
+		clear   := true
+		prev    := p1
+		for k := 1; k <= samples && clear; k++ {
+			q := p2
+			if k < samples {
+				q = catmullRomPoint(p0, p1, p2, p3, float64(k) / float64(samples))
+			}
+			clear = pointsVisible(prev, q, isOpen)
+			curve = append(curve, q)
+			prev  = q
+		}
+		if clear {
+			smoothPath = append(smoothPath, curve...)
+		} else {
+			smoothPath = append(smoothPath, p2)
+		}
+	}
+	return smoothPath
+}
+
+// The point of the centripetal Catmull-Rom spline between p1 and p2 at
+// the fraction s of the way (Barry and Goldman's pyramidal formulation)
+func catmullRomPoint(p0, p1, p2, p3 Point, s float64) Point {
+	t0 := 0.0
+	t1 := t0 + math.Sqrt(PointDist(p0, p1))
+	t2 := t1 + math.Sqrt(PointDist(p1, p2))
+	t3 := t2 + math.Sqrt(PointDist(p2, p3))
+	t  := t1 + s * (t2 - t1)
+	lerp := func(a, b Point, ta, tb float64) Point {
+		if tb == ta {
+			return a
+		}
+		u := (t - ta) / (tb - ta)
+		return Point{a.X + u*(b.X - a.X), a.Y + u*(b.Y - a.Y)}
+	}
+	a1 := lerp(p0, p1, t0, t1)
+	a2 := lerp(p1, p2, t1, t2)
+	a3 := lerp(p2, p3, t2, t3)
+	b1 := lerp(a1, a2, t0, t2)
+	b2 := lerp(a2, a3, t1, t3)
+	return lerp(b1, b2, t1, t2)
+}
+
+// Whether a point is a cell corner or a cell center, so that doubling its
+// coordinates gives integers
+func onHalfGrid(p Point) bool {
+	return 2*p.X == math.Round(2*p.X) && 2*p.Y == math.Round(2*p.Y)
+}
+
+/*
+ * Whether an agent can go straight from a to b, with the same rules as
+ * lineOfSight. Cell corners and centers are tested exactly on half cells,
+ * like lineOfSight does in the cell-center model. Other points are tested
+ * in floating point, which rejects lines that pass a blocked cell closer
+ * than its rounding errors. cellOpen decides which cells are open.
+ */
+func pointsVisible(a, b Point, cellOpen func(int, int) bool) bool {
+	if connectivity == FourConnected && a.X != b.X && a.Y != b.Y {
+		return false
+	}
+	if agentSize > 0 && nodeModel == GridCorners && (a.X == b.X || a.Y == b.Y) {
+		return agentLineClear(a, b, cellOpen)
+	}
+	canTouch := canTouchCorner
+	if nodeModel == CellCenters {
+		canTouch = diagonalAllowed
+	}
+	if onHalfGrid(a) && onHalfGrid(b) {
+		halfCellOpen := func(x, y int) bool {
+			return cellOpen(floorDiv(x, 2), floorDiv(y, 2))
+		}
+		return segmentClear(int(2*a.X), int(2*a.Y), int(2*b.X), int(2*b.Y), halfCellOpen, canTouch)
+	}
+	return floatSegmentClear(a, b, cellOpen, canTouch)
+}
+
+/*
+ * segmentClear for any two points: the line may not enter a blocked cell,
+ * a horizontal or vertical line along grid edges needs an open cell on
+ * one side, and the side cells of the grid corners that other lines pass
+ * must pass canTouch.
+ */
+func floatSegmentClear(a, b Point, cellOpen func(int, int) bool, canTouch func(bool, bool) bool) bool {
+	const epsilon = 1e-9
+	if a.X > b.X {
+		a, b = b, a
+	}
+	if a.Y == b.Y || a.X == b.X {
+		// Swap the axes of vertical lines
+		swapped := a.X == b.X
+		from, to, at := a.X, b.X, a.Y
+		open := cellOpen
+		if swapped {
+			from, to, at = math.Min(a.Y, b.Y), math.Max(a.Y, b.Y), a.X
+			open = func(x, y int) bool {
+				return cellOpen(y, x)
+			}
+		}
+		for c := int(math.Floor(from)); float64(c) < to; c++ {
+			if float64(c+1) <= from {
+				continue
+			}
+			if at == math.Floor(at) {
+				if !open(c, int(at)-1) && !open(c, int(at)) {
+					return false
+				}
+			} else if !open(c, int(math.Floor(at))) {
+				return false
+			}
+		}
+		return true
+	}
+
+	slope := (b.Y - a.Y) / (b.X - a.X)
+	yAt := func(x float64) float64 {
+		return a.Y + (x - a.X) * slope
+	}
+	// Cells whose interior the line enters
+	for c := int(math.Floor(a.X)); float64(c) < b.X; c++ {
+		lo, hi := math.Max(float64(c), a.X), math.Min(float64(c+1), b.X)
+		if hi <= lo {
+			continue
+		}
+		yLo, yHi := yAt(lo), yAt(hi)
+		if yLo > yHi {
+			yLo, yHi = yHi, yLo
+		}
+		for r := int(math.Floor(yLo)); float64(r) < yHi; r++ {
+			if float64(r+1) > yLo && !cellOpen(c, r) {
+				return false
+			}
+		}
+	}
+	// Grid corners on the line, see segmentClear for the side cells
+	r := 0
+	if b.Y < a.Y {
+		r = -1
+	}
+	for c := int(math.Ceil(a.X)); float64(c) <= b.X; c++ {
+		y := yAt(float64(c))
+		if math.Abs(y - math.Round(y)) < epsilon {
+			row := int(math.Round(y))
+			if !canTouch(cellOpen(c, row-1-r), cellOpen(c-1, row+r)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// The cells whose closed square the line from a to b touches
+func cellsTouched(a, b Point) [][2]int {
+	if a.X > b.X {
+		a, b = b, a
+	}
+	cells := [][2]int{}
+	for c := int(math.Floor(a.X)) - 1; float64(c) <= b.X; c++ {
+		lo, hi := math.Max(float64(c), a.X), math.Min(float64(c+1), b.X)
+		if hi < lo {
+			continue
+		}
+		yLo, yHi := a.Y, a.Y
+		if b.X != a.X {
+			yLo = a.Y + (lo - a.X) * (b.Y - a.Y) / (b.X - a.X)
+			yHi = a.Y + (hi - a.X) * (b.Y - a.Y) / (b.X - a.X)
+		} else {
+			yHi = b.Y
+		}
+		if yLo > yHi {
+			yLo, yHi = yHi, yLo
+		}
+		for r := int(math.Floor(yLo)) - 1; float64(r) <= yHi; r++ {
+			if float64(r+1) >= yLo {
+				cells = append(cells, [2]int{c, r})
+			}
+		}
+	}
+	return cells
+}
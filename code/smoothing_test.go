@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+/*
+ * Smooths A* paths between random nodes on random maps of up to 16x16
+ * cells with every smoothing step, in every node model and connectivity.
+ * The smoothed paths must still be valid lines with line of sight, and
+ * the steps that drop points must not make them longer.
+ */
+func TestSmoothing(t *testing.T) {
+	keepGlobals(t)
+	oldSteps := smoothingSteps
+	t.Cleanup(func() {
+		smoothingSteps = oldSteps
+	})
+	const epsilon = 1e-6
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		g := randomGrid(random, 16, 16, 0.3)
+		for _, model := range(allNodeModels) {
+			for _, c := range(allConnectivities) {
+				nodeModel, connectivity = model, c
+				setGrid(g)
+				maxX, maxY := maxNodeCoordinates()
+				start := NewNode(random.Intn(maxX+1), random.Intn(maxY+1))
+				goal  := NewNode(random.Intn(maxX+1), random.Intn(maxY+1))
+				path  := NodesToPoints(AStar(start, goal))
+				for _, step := range(smoothingNames) {
+					smoothingSteps = []string{step}
+					smoothed := SmoothPath(path)
+					prefix   := fmt.Sprintf("map %d, %s model, %s-connectivity, %s: path (%d,%d) -> (%d,%d)",
+						i, nodeModelName(model), connectivityName(c), step, start.X, start.Y, goal.X, goal.Y)
+					if err := ValidatePath(smoothed, start, goal, Lines); err != nil {
+						t.Errorf("%s: %s\n%s", prefix, err.Error(), gridString(g))
+					}
+					if step != "catmull-rom" && PointPathLength(smoothed) > PointPathLength(path) + epsilon {
+						t.Errorf("%s has length %f, longer than %f before\n%s",
+							prefix, PointPathLength(smoothed), PointPathLength(path), gridString(g))
+					}
+				}
+			}
+		}
+	}
+}
+
+/*
+ * Compares string-pull on A* paths with the shortest path through the
+ * same corridor by brute force: Dijkstra over the points of the path and
+ * every corner and center of the corridor cells, where any two points
+ * that see each other in the corridor are connected. The pulled path must
+ * be as short and stay in the corridor, on random maps of up to 12x12
+ * cells in every node model and connectivity.
+ */
+func TestStringPull(t *testing.T) {
+	keepGlobals(t)
+	const epsilon = 1e-6
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 60; i++ {
+		g := randomGrid(random, 12, 12, 0.4)
+		for _, model := range(allNodeModels) {
+			for _, c := range(allConnectivities) {
+				nodeModel, connectivity = model, c
+				setGrid(g)
+				maxX, maxY := maxNodeCoordinates()
+				start := NewNode(random.Intn(maxX+1), random.Intn(maxY+1))
+				goal  := NewNode(random.Intn(maxX+1), random.Intn(maxY+1))
+				path  := NodesToPoints(AStar(start, goal))
+				if len(path) < 3 {
+					continue
+				}
+				corridor := corridorCells(path)
+				inCorridor := func(x, y int) bool {
+					return corridor[[2]int{x, y}]
+				}
+				// Consecutive points of the path are connected like in pullString
+				isPathEdge := map[[2]Point]bool{}
+				for k := 0; k+1 < len(path); k++ {
+					isPathEdge[[2]Point{path[k], path[k+1]}] = true
+					isPathEdge[[2]Point{path[k+1], path[k]}] = true
+				}
+				connected := func(a, b Point) bool {
+					return isPathEdge[[2]Point{a, b}] || pointsVisible(a, b, inCorridor)
+				}
+
+				pulled := pullString(path)
+				prefix := fmt.Sprintf("map %d, %s model, %s-connectivity: path (%d,%d) -> (%d,%d)",
+					i, nodeModelName(model), connectivityName(c), start.X, start.Y, goal.X, goal.Y)
+				for k := 0; k+1 < len(pulled); k++ {
+					if !connected(pulled[k], pulled[k+1]) {
+						t.Errorf("%s: the pulled path leaves the corridor from (%g,%g) to (%g,%g)\n%s",
+							prefix, pulled[k].X, pulled[k].Y, pulled[k+1].X, pulled[k+1].Y, gridString(g))
+					}
+				}
+				if pulled[0] != path[0] || pulled[len(pulled)-1] != path[len(path)-1] {
+					t.Errorf("%s: the pulled path has other ends\n%s", prefix, gridString(g))
+				}
+
+				points := append([]Point{}, path...)
+				for cell := range(corridor) {
+					x, y := float64(cell[0]), float64(cell[1])
+					points = append(points, Point{x, y}, Point{x+1, y}, Point{x, y+1}, Point{x+1, y+1}, Point{x+0.5, y+0.5})
+				}
+				dist := make([]float64, len(points))
+				done := make([]bool, len(points))
+				for k := range(dist) {
+					dist[k] = math.Inf(1)
+				}
+				dist[0] = 0
+				for {
+					u := -1
+					for k := range(points) {
+						if !done[k] && (u == -1 || dist[k] < dist[u]) {
+							u = k
+						}
+					}
+					if u == -1 || math.IsInf(dist[u], 1) {
+						break
+					}
+					done[u] = true
+					for v := range(points) {
+						if d := dist[u] + PointDist(points[u], points[v]); !done[v] && d < dist[v] && connected(points[u], points[v]) {
+							dist[v] = d
+						}
+					}
+				}
+				if expected := dist[len(path)-1]; math.Abs(PointPathLength(pulled) - expected) > epsilon {
+					t.Errorf("%s: the pulled path has length %f, the shortest path in the corridor %f\n%s",
+						prefix, PointPathLength(pulled), expected, gridString(g))
+				}
+			}
+		}
+	}
+}
+
+/*
+ * A path between grid corners with 8-connectivity that runs along the top
+ * of the map and down its right side, around a block of three cells. The
+ * greedy steps keep the last point that the start sees, which is on the
+ * right side, while string-pull makes the path taut around the corner of
+ * the block. Catmull-Rom keeps the ends.
+ */
+func TestSmoothingAroundBlock(t *testing.T) {
+	keepGlobals(t)
+	oldSteps := smoothingSteps
+	t.Cleanup(func() {
+		smoothingSteps = oldSteps
+	})
+	nodeModel, connectivity = GridCorners, EightConnected
+	setGrid(gridFromRows(
+		".....",
+		".@@@.",
+		".....",
+	))
+	path := []Point{{0, 0}, {1, 0}, {2, 0}, {3, 0}, {4, 0}, {5, 0}, {5, 1}, {5, 2}, {5, 3}}
+	expected := map[string][]Point{
+		"greedy":        {{0, 0}, {5, 1}, {5, 3}},
+		"greedy-repeat": {{0, 0}, {5, 1}, {5, 3}},
+		"string-pull":   {{0, 0}, {4, 1}, {5, 3}},
+	}
+	for _, step := range(smoothingNames) {
+		smoothingSteps = []string{step}
+		smoothed := SmoothPath(path)
+		if step == "catmull-rom" {
+			if smoothed[0] != path[0] || smoothed[len(smoothed)-1] != path[len(path)-1] {
+				t.Errorf("catmull-rom runs from (%g,%g) to (%g,%g)",
+					smoothed[0].X, smoothed[0].Y, smoothed[len(smoothed)-1].X, smoothed[len(smoothed)-1].Y)
+			}
+			continue
+		}
+		if fmt.Sprint(smoothed) != fmt.Sprint(expected[step]) {
+			t.Errorf("%s gives %v, expected %v", step, smoothed, expected[step])
+		}
+	}
+}
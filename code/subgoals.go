@@ -0,0 +1,349 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+/*
+ * A simple subgoal graph (Uras, Koenig and Hernández 2013). Subgoals are
+ * the nodes next to the convex corners of obstacles, where shortest paths
+ * turn. Two nodes are h-reachable if a path between them is as short as
+ * the heuristic, which then is the exact distance, and subgoals are
+ * connected when a path between them needs no other subgoal. Any shortest
+ * path can be made of such edges, so searching the graph is optimal.
+ */
+type SubgoalGraph struct {
+	Model         NodeModel
+	Connectivity  Connectivity
+	Subgoals      map[Node]bool
+	Edges         map[Node][]HPAEdge // The same edges as in the HPA* graph
+	clearance     [4][][]int  // Straight moves from each node until an obstacle or a subgoal
+	endsAtSubgoal [4][][]bool // Whether those moves end next to a subgoal
+}
+
+// The directions of the clearances
+var cardinalDirections = [4][2]int{{1,0}, {-1,0}, {0,1}, {0,-1}}
+
+var subgoalGraph *SubgoalGraph // The graph of the current grid, nil until it is built
+var subgoalFallbacks int       // Searches since the graph was made that needed AStar
+
+const hEpsilon = 1e-9
+
+/*
+ * Whether n is a subgoal, which is where shortest paths may have to turn
+ * around the corner of an obstacle. The rules only look at the moves, so
+ * they work for every node model and connectivity. A path turns at n
+ * from a straight move to a neighbour v, as short as the heuristic from
+ * the node u before it to v, but no other path that short leads from u
+ * to v. Or two neighbours of n on different axes can not reach each other
+ * as directly as the heuristic, which is the rule of the paper.
+ */
+func isSubgoal(n Node) bool {
+	if !blockedNearby(n) {
+		return false
+	}
+	neighbours := getTraversableNodes(n)
+	for _, u := range(neighbours) {
+		if math.Abs(float64(n.X - u.X)) + math.Abs(float64(n.Y - u.Y)) != 1 {
+			continue // u -> n is not straight
+		}
+		for _, v := range(neighbours) {
+			if v == u || (v.X - n.X == n.X - u.X && v.Y - n.Y == n.Y - u.Y) {
+				continue // No turn
+			}
+			h := gridHeuristic(u, v)
+			if math.Abs(costToNeighbour(u, n) + costToNeighbour(n, v) - h) > hEpsilon {
+				continue
+			}
+			alternative := canMove(u, v) && math.Abs(costToNeighbour(u, v) - h) < hEpsilon
+			for _, w := range(getTraversableNodes(u)) {
+				if w != n && canMove(w, v) && math.Abs(costToNeighbour(u, w) + costToNeighbour(w, v) - h) < hEpsilon {
+					alternative = true
+				}
+			}
+			if !alternative {
+				return true
+			}
+		}
+	}
+
+	// Without corner cutting the way around a corner through n is longer
+	// than the heuristic, so the first rule misses it
+	for _, d := range([][2]int{{1,1}, {1,-1}, {-1,1}, {-1,-1}}) {
+		a := NewNode(n.X + d[0], n.Y)
+		b := NewNode(n.X, n.Y + d[1])
+		if !canMove(n, a) || !canMove(n, b) {
+			continue
+		}
+		h := gridHeuristic(a, b)
+		diagonal := canMove(a, b) && math.Abs(SQRT2 - h) < hEpsilon
+		opposite := NewNode(n.X + d[0], n.Y + d[1])
+		around   := canMove(a, opposite) && canMove(opposite, b) && math.Abs(2 - h) < hEpsilon
+		if !diagonal && !around {
+			return true
+		}
+	}
+	return false
+}
+
+// The rules of isSubgoal look at moves up to two nodes away from n, which
+// only depend on cells up to four cells away. Nodes without blocked cells
+// there are not subgoals, and most nodes of a map are like that.
+func blockedNearby(n Node) bool {
+	for y := n.Y-4; y <= n.Y+4; y++ {
+		for x := n.X-4; x <= n.X+4; x++ {
+			if !isOpen(x, y) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+/*
+ * Finds the subgoals and connects each one with the subgoals that are
+ * directly h-reachable from it. Prints the size of the graph.
+ */
+func preprocessSubgoals(mapPath string) {
+	subgoalGraph = buildSubgoalGraph()
+	edges := 0
+	for _, e := range(subgoalGraph.Edges) {
+		edges += len(e)
+	}
+	fmt.Printf("Built the subgoal graph: %d subgoals, %d edges\n", len(subgoalGraph.Subgoals), edges)
+}
+
+func buildSubgoalGraph() *SubgoalGraph {
+	subgoalFallbacks = 0
+	graph := &SubgoalGraph{Model: nodeModel, Connectivity: connectivity, Subgoals: map[Node]bool{}, Edges: map[Node][]HPAEdge{}}
+	maxX, maxY := maxNodeCoordinates()
+	for y := 0; y <= maxY; y++ {
+		for x := 0; x <= maxX; x++ {
+			if n := NewNode(x, y); isSubgoal(n) {
+				graph.Subgoals[n] = true
+			}
+		}
+	}
+
+	// Each clearance follows from the clearance of the next node in its
+	// direction, so the nodes are visited starting from the far side
+	for i, d := range(cardinalDirections) {
+		graph.clearance[i]     = make([][]int, maxY+1)
+		graph.endsAtSubgoal[i] = make([][]bool, maxY+1)
+		for y := range(graph.clearance[i]) {
+			graph.clearance[i][y]     = make([]int, maxX+1)
+			graph.endsAtSubgoal[i][y] = make([]bool, maxX+1)
+		}
+		for j := 0; j <= maxY; j++ {
+			for k := 0; k <= maxX; k++ {
+				x, y := k, j
+				if d[0] > 0 {
+					x = maxX - k
+				}
+				if d[1] > 0 {
+					y = maxY - j
+				}
+				n    := NewNode(x, y)
+				next := NewNode(x + d[0], y + d[1])
+				if !canMove(n, next) {
+					continue
+				}
+				if graph.Subgoals[next] {
+					graph.endsAtSubgoal[i][y][x] = true
+					continue
+				}
+				graph.clearance[i][y][x]     = 1 + graph.clearance[i][next.Y][next.X]
+				graph.endsAtSubgoal[i][y][x] = graph.endsAtSubgoal[i][next.Y][next.X]
+			}
+		}
+	}
+
+	for s := range(graph.Subgoals) {
+		graph.Edges[s] = directHReachable(graph, s)
+	}
+	return graph
+}
+
+/*
+ * Returns the subgoals that are directly h-reachable from n with their
+ * distances. Like in the paper, the paths looked at go diagonally first
+ * and then straight. Going straight from each node on a diagonal stops at
+ * the first subgoal or obstacle, and never gets further than from the
+ * previous node on the diagonal, because beyond that the way is blocked
+ * or leads through a subgoal. With 4-connectivity there are no diagonals,
+ * so the h-reachable nodes are flooded instead.
+ */
+func directHReachable(graph *SubgoalGraph, n Node) []HPAEdge {
+	if connectivity == FourConnected {
+		return floodHReachable(n, graph.Subgoals)
+	}
+	found := []HPAEdge{}
+	seen  := map[Node]bool{}
+	add   := func(t Node) {
+		if !seen[t] {
+			seen[t] = true
+			found = append(found, HPAEdge{t, gridHeuristic(n, t)})
+		}
+	}
+	// Moves from p in cardinal direction i until an obstacle or a subgoal,
+	// at most limit times. Returns how many moves were made.
+	scan := func(p Node, i int, limit int) int {
+		k := graph.clearance[i][p.Y][p.X]
+		if k >= limit {
+			return limit
+		}
+		if graph.endsAtSubgoal[i][p.Y][p.X] {
+			d := cardinalDirections[i]
+			add(NewNode(p.X + (k+1)*d[0], p.Y + (k+1)*d[1]))
+		}
+		return k
+	}
+	unlimited := len(grid) + len(grid[0]) + 2
+	for _, d := range([][2]int{{1,1}, {1,-1}, {-1,1}, {-1,-1}}) {
+		c1, c2 := 0, 2 // The indices of the cardinal directions of d
+		if d[0] < 0 {
+			c1 = 1
+		}
+		if d[1] < 0 {
+			c2 = 3
+		}
+		max1 := scan(n, c1, unlimited)
+		max2 := scan(n, c2, unlimited)
+		for p := n; ; {
+			next := NewNode(p.X + d[0], p.Y + d[1])
+			if !canMove(p, next) {
+				break
+			}
+			if graph.Subgoals[next] {
+				add(next)
+				break
+			}
+			p = next
+			max1 = scan(p, c1, max1)
+			max2 = scan(p, c2, max2)
+		}
+	}
+	return found
+}
+
+// Visits every node that is h-reachable from n without going through
+// subgoals. A node is h-reachable when it has an h-reachable neighbour
+// and the move from it adds exactly the difference of the heuristics.
+func floodHReachable(n Node, subgoals map[Node]bool) []HPAEdge {
+	found   := []HPAEdge{}
+	visited := map[Node]bool{n: true}
+	stack   := []Node{n}
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, neighbour := range(getTraversableNodes(node)) {
+			if visited[neighbour] {
+				continue
+			}
+			h := gridHeuristic(n, neighbour)
+			if math.Abs(gridHeuristic(n, node) + costToNeighbour(node, neighbour) - h) > hEpsilon {
+				continue
+			}
+			visited[neighbour] = true
+			if subgoals[neighbour] {
+				found = append(found, HPAEdge{neighbour, h})
+			} else {
+				stack = append(stack, neighbour)
+			}
+		}
+	}
+	noteStoredNodes(len(visited))
+	return found
+}
+
+/*
+ * A path from one node to another that is as long as the heuristic, or an
+ * empty path if there is none. Only nodes on such paths are visited.
+ */
+func hReachablePath(from, to Node) []Node {
+	total   := gridHeuristic(from, to)
+	parents := map[Node]Node{}
+	stack   := []Node{from}
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if node == to {
+			path := []Node{to}
+			for n := to; n != from; {
+				n = parents[n]
+				path = append([]Node{n}, path...)
+			}
+			return path
+		}
+		for _, neighbour := range(getTraversableNodes(node)) {
+			if _, seen := parents[neighbour]; seen || neighbour == from {
+				continue
+			}
+			h := gridHeuristic(from, neighbour)
+			if math.Abs(gridHeuristic(from, node) + costToNeighbour(node, neighbour) - h) > hEpsilon ||
+			   math.Abs(h + gridHeuristic(neighbour, to) - total) > hEpsilon {
+				continue
+			}
+			parents[neighbour] = node
+			stack = append(stack, neighbour)
+		}
+	}
+	return []Node{}
+}
+
+/*
+ * Searches the subgoal graph. The start and the goal are connected to the
+ * subgoals that are directly h-reachable from them, unless the goal can be
+ * reached without turning at a subgoal, and every edge of the path found
+ * is refined into an h-reachable path. The graph should connect all
+ * connected nodes with edges that can be refined. If it does not, AStar
+ * finds the path and subgoalFallbacks counts the search, like in HPAStar.
+ */
+func SubgoalSearch(start, goal Node) []Node {
+	if !sameComponent(start, goal) {
+		return []Node{}
+	}
+	if subgoalGraph == nil || subgoalGraph.Model != nodeModel || subgoalGraph.Connectivity != connectivity {
+		subgoalGraph = buildSubgoalGraph()
+	}
+	if path := hReachablePath(start, goal); len(path) > 0 {
+		return path
+	}
+
+	links := map[Node][]HPAEdge{}
+	if !subgoalGraph.Subgoals[start] {
+		links[start] = directHReachable(subgoalGraph, start)
+	}
+	if !subgoalGraph.Subgoals[goal] {
+		// Moves are symmetric, so the subgoals that the goal reaches reach it
+		for _, e := range(directHReachable(subgoalGraph, goal)) {
+			links[e.To] = append(links[e.To], HPAEdge{goal, e.Cost})
+		}
+	}
+
+	abstractPath := abstractGraphSearch(start, goal, subgoalGraph.Edges, links)
+	if len(abstractPath) == 0 {
+		subgoalFallbacks++
+		return AStar(start, goal)
+	}
+	path := []Node{start}
+	for i := 0; i < len(abstractPath)-1; i++ {
+		segment := hReachablePath(abstractPath[i], abstractPath[i+1])
+		if len(segment) == 0 {
+			subgoalFallbacks++
+			return AStar(start, goal)
+		}
+		path = append(path, segment[1:]...)
+	}
+	return path
+}
+
+// Prints how many searches the subgoal graph left to AStar since the last
+// report
+func reportSubgoalFallbacks() {
+	if subgoalFallbacks > 0 {
+		fmt.Printf("Note: the subgoal graph search fell back to A* in %d search(es) that it could not connect\n", subgoalFallbacks)
+		subgoalFallbacks = 0
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+/*
+ * Compares the paths of the subgoal graph with A* between random nodes on
+ * random maps of up to 24x24 cells, in every node model and connectivity.
+ * Which nodes are subgoals depends on the moves, so a wrong rule shows up
+ * as a longer path or a missing one.
+ */
+func TestSubgoalSearch(t *testing.T) {
+	keepGlobals(t)
+	const epsilon = 1e-6
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		g := randomGrid(random, 24, 24, 0.5)
+		for _, model := range(allNodeModels) {
+			for _, c := range(allConnectivities) {
+				nodeModel, connectivity = model, c
+				setGrid(g)
+				for j := 0; j < 20; j++ {
+					start    := randomNode(random)
+					goal     := randomNode(random)
+					expected := AStar(start, goal)
+					got      := SubgoalSearch(start, goal)
+					prefix   := fmt.Sprintf("map %d, %s model, %s-connectivity: subgoal path (%d,%d) -> (%d,%d)",
+						i, nodeModelName(model), connectivityName(c), start.X, start.Y, goal.X, goal.Y)
+					if problem := pathProblem(got, start, goal); problem != "" {
+						t.Errorf("%s: %s\n%s", prefix, problem, gridString(g))
+					}
+					if (len(got) == 0) != (len(expected) == 0) || math.Abs(PathLength(got) - PathLength(expected)) > epsilon {
+						t.Errorf("%s has length %f, astar %f\n%s", prefix, PathLength(got), PathLength(expected), gridString(g))
+					}
+				}
+			}
+		}
+	}
+}
+
+/*
+ * Replaces the edges of a subgoal graph with free edges between all the
+ * subgoals, most of which cannot be refined into h-reachable paths. The
+ * search must then fall back to A* and count it instead of failing.
+ */
+func TestSubgoalSearchFallback(t *testing.T) {
+	keepGlobals(t)
+	const epsilon = 1e-6
+	g := gridFromRows(
+		"..........",
+		"..@@..@...",
+		"..@...@@..",
+		".....@....",
+		"..@@......",
+	)
+	for _, model := range(allNodeModels) {
+		for _, c := range(allConnectivities) {
+			nodeModel, connectivity = model, c
+			setGrid(g)
+			subgoalGraph = buildSubgoalGraph()
+			for a := range(subgoalGraph.Subgoals) {
+				subgoalGraph.Edges[a] = nil
+				for b := range(subgoalGraph.Subgoals) {
+					if a != b {
+						subgoalGraph.Edges[a] = append(subgoalGraph.Edges[a], HPAEdge{b, 0})
+					}
+				}
+			}
+			fallbacks := 0
+			maxX, maxY := maxNodeCoordinates()
+			nodes := (maxX+1) * (maxY+1)
+			for i := 0; i < nodes*nodes; i++ {
+				start    := NewNode(i / nodes % (maxX+1), i / nodes / (maxX+1))
+				goal     := NewNode(i % nodes % (maxX+1), i % nodes / (maxX+1))
+				expected := AStar(start, goal)
+				got      := SubgoalSearch(start, goal)
+				prefix   := fmt.Sprintf("%s model, %s-connectivity: subgoal path (%d,%d) -> (%d,%d)",
+					nodeModelName(model), connectivityName(c), start.X, start.Y, goal.X, goal.Y)
+				if problem := pathProblem(got, start, goal); problem != "" {
+					t.Errorf("%s: %s", prefix, problem)
+				}
+				if (len(got) == 0) != (len(expected) == 0) || PathLength(got) < PathLength(expected) - epsilon {
+					t.Errorf("%s has length %f, astar %f", prefix, PathLength(got), PathLength(expected))
+				}
+				fallbacks += subgoalFallbacks
+				subgoalFallbacks = 0
+			}
+			if fallbacks == 0 {
+				t.Errorf("%s model, %s-connectivity: no search fell back to A*", nodeModelName(model), connectivityName(c))
+			}
+		}
+	}
+}
+
+/*
+ * The subgoals around a single blocked cell in the middle of a 5x5 map.
+ * Between grid corners with 8-connectivity paths turn at the corners of
+ * the cell, and with 4-connectivity they never have to. Between cell
+ * centers paths that may cut the corner turn next to the cell, and the
+ * others diagonally next to it.
+ */
+func TestSubgoalsAroundCell(t *testing.T) {
+	keepGlobals(t)
+	g := gridFromRows(
+		".....",
+		".....",
+		"..@..",
+		".....",
+		".....",
+	)
+	cases := []struct {
+		model    NodeModel
+		c        Connectivity
+		subgoals string
+	}{
+		{GridCorners, EightConnected, "[(2,2) (2,3) (3,2) (3,3)]"},
+		{GridCorners, FourConnected, "[]"},
+		{CellCenters, EightConnected, "[(1,2) (2,1) (2,3) (3,2)]"},
+		{CellCenters, EightConnectedNoCornerCutting, "[(1,1) (1,3) (3,1) (3,3)]"},
+		{CellCenters, FourConnected, "[(1,1) (1,3) (3,1) (3,3)]"},
+	}
+	for _, test := range(cases) {
+		nodeModel, connectivity = test.model, test.c
+		setGrid(g)
+		graph := buildSubgoalGraph()
+		got := []string{}
+		for n := range(graph.Subgoals) {
+			got = append(got, fmt.Sprintf("(%d,%d)", n.X, n.Y))
+		}
+		sort.Strings(got)
+		if fmt.Sprint(got) != test.subgoals {
+			t.Errorf("%s model, %s-connectivity: the subgoals are %v, expected %s",
+				nodeModelName(test.model), connectivityName(test.c), got, test.subgoals)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+/*
+ * How the best-first searches choose between open nodes with the same f
+ * score. On open maps most nodes towards the goal tie, so the policy
+ * decides whether the search runs straight to the goal or fills the area
+ * around the start first.
+ */
+type TieBreaking int
+const (
+	LIFO     TieBreaking = iota // The node whose f score was updated last
+	FIFO                        // The node whose f score was updated first
+	HigherG                     // The node furthest from the start, then LIFO
+	LowerH                      // The node closest to the goal, then LIFO
+	RandomTies                  // A random order of the nodes given by the seed, then LIFO
+)
+
+var tieBreakingNames = []string{"lifo", "fifo", "higher-g", "lower-h", "random"}
+
+func (t TieBreaking) String() string {
+	return tieBreakingNames[t]
+}
+
+var tieBreaking           = LIFO
+var tieBreakingSeed int64 = 1 // The seed of RandomTies
+
+// Nodes taken from the open list by the last search of an algorithm that
+// follows the tie-breaking policy, 0 for the other algorithms
+var expansions int
+
+func MustParseTieBreaking(value string) TieBreaking {
+	for i, name := range(tieBreakingNames) {
+		if name == strings.ToLower(value) {
+			return TieBreaking(i)
+		}
+	}
+	fmt.Printf("Unknown tie-breaking policy \"%s\", accepted policies are \"%s\"\n", value, strings.Join(tieBreakingNames, "\", \""))
+	os.Exit(1)
+	return LIFO
+}
+
+/*
+ * Whether node a comes before node b when their f scores are equal. The
+ * g and h scores and the timestamps are those of the nodes. Timestamps
+ * are unique within a search, so the order is total and the searches do
+ * not depend on the order in which the open nodes are visited. Entries
+ * with equal timestamps that the policy does not tell apart are a tie,
+ * and neither comes before the other.
+ */
+func tieBreaksBefore(a Node, aG, aH float64, aTimestamp int, b Node, bG, bH float64, bTimestamp int) bool {
+	switch tieBreaking {
+		case FIFO:
+			return aTimestamp < bTimestamp
+		case HigherG:
+			if aG != bG {
+				return aG > bG
+			}
+		case LowerH:
+			if aH != bH {
+				return aH < bH
+			}
+		case RandomTies:
+			if ra, rb := randomRank(a), randomRank(b); ra != rb {
+				return ra < rb
+			}
+	}
+	return aTimestamp > bTimestamp
+}
+
+// A pseudo-random number for each node, the same in every search with the
+// same seed so that benchmark trials repeat the same search (SplitMix64)
+func randomRank(n Node) uint64 {
+	z := uint64(tieBreakingSeed) ^ (uint64(uint32(n.X)) << 32 | uint64(uint32(n.Y)))
+	z += 0x9e3779b97f4a7c15
+	z  = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z  = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
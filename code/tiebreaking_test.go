@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+/*
+ * Checks that every tie-breaking policy orders random entries strictly:
+ * no entry comes before itself, at most one of two entries comes before
+ * the other, and entries with equal timestamps are a tie unless the policy
+ * tells them apart. The policies must prefer what their names say.
+ */
+func TestTieBreakingOrder(t *testing.T) {
+	oldPolicy, oldSeed := tieBreaking, tieBreakingSeed
+	t.Cleanup(func() {
+		tieBreaking, tieBreakingSeed = oldPolicy, oldSeed
+	})
+	random := rand.New(rand.NewSource(1))
+	for policy := range(tieBreakingNames) {
+		tieBreaking = TieBreaking(policy)
+		for i := 0; i < 1000; i++ {
+			a, b := NewNode(random.Intn(4), random.Intn(4)), NewNode(random.Intn(4), random.Intn(4))
+			aG, bG := float64(random.Intn(3)), float64(random.Intn(3))
+			aH, bH := float64(random.Intn(3)), float64(random.Intn(3))
+			aT, bT := random.Intn(3), random.Intn(3)
+			prefix := fmt.Sprintf("%s: (%d,%d) g %g h %g timestamp %d and (%d,%d) g %g h %g timestamp %d",
+				tieBreaking, a.X, a.Y, aG, aH, aT, b.X, b.Y, bG, bH, bT)
+			if tieBreaksBefore(a, aG, aH, aT, a, aG, aH, aT) {
+				t.Errorf("%s: the first comes before itself", prefix)
+			}
+			before, after := tieBreaksBefore(a, aG, aH, aT, b, bG, bH, bT), tieBreaksBefore(b, bG, bH, bT, a, aG, aH, aT)
+			if before && after {
+				t.Errorf("%s: each comes before the other", prefix)
+			}
+			if aT != bT && !before && !after && (tieBreaking != RandomTies || randomRank(a) == randomRank(b)) {
+				t.Errorf("%s: unexpected tie", prefix)
+			}
+			switch {
+				case tieBreaking == FIFO && aT < bT && !before,
+				     tieBreaking == LIFO && aT > bT && !before,
+				     tieBreaking == HigherG && aG > bG && !before,
+				     tieBreaking == LowerH && aH < bH && !before:
+					t.Errorf("%s: the first does not come first", prefix)
+			}
+		}
+	}
+}
+
+/*
+ * The policies only change which of the shortest paths is found, so the
+ * lengths of the searches that follow them must not change on random maps
+ * of up to 16x16 cells.
+ */
+func TestTieBreakingLengths(t *testing.T) {
+	keepGlobals(t)
+	oldPolicy := tieBreaking
+	t.Cleanup(func() {
+		tieBreaking = oldPolicy
+	})
+	const epsilon = 1e-6
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 50; i++ {
+		setGrid(randomGrid(random, 16, 16, 0.4))
+		maxX, maxY := maxNodeCoordinates()
+		start := NewNode(random.Intn(maxX+1), random.Intn(maxY+1))
+		goal  := NewNode(random.Intn(maxX+1), random.Intn(maxY+1))
+		expected := PathLength(Dijkstra(start, goal))
+		for policy := range(tieBreakingNames) {
+			tieBreaking = TieBreaking(policy)
+			for _, name := range([]string{"dijkstra", "astar", "bidijkstra", "biastar", "alt"}) {
+				length := PointPathLength(MustParsePathfindingFunction(name)(start, goal))
+				if math.Abs(length - expected) > epsilon {
+					t.Errorf("map %d, %s ties: %s path (%d,%d) -> (%d,%d) has length %f, expected %f\n%s",
+						i, tieBreaking, name, start.X, start.Y, goal.X, goal.Y, length, expected, gridString(grid))
+				}
+			}
+		}
+	}
+}
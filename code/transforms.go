@@ -0,0 +1,261 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+ * Geometric transforms that make new maps from a map, for benchmarking on
+ * rotated, mirrored, cropped, larger and tiled versions of it, and the
+ * chains of transforms that the transform mode applies to a map and its
+ * scenarios. Scenario coordinates are cells, like in the movingai files,
+ * so a start or goal moves with its cell and stays on an open cell.
+ */
+
+// One transform of a chain, with its parameters
+type TransformStep struct {
+	Name   string
+	Params []int
+}
+
+var geometryNames = []string{"crop", "rotate", "flip-x", "flip-y", "scale", "tile"}
+
+// The number of parameters of each step and an example of it
+var transformParams = map[string]int{
+	"inflate": 1, "erode": 1, "close": 1, "remove-small": 1,
+	"crop": 4, "rotate": 1, "flip-x": 0, "flip-y": 0, "scale": 1, "tile": 2,
+}
+var transformExamples = map[string]string{
+	"inflate": "inflate:1", "erode": "erode:1", "close": "close:1", "remove-small": "remove-small:20",
+	"crop": "crop:0:0:64:64", "rotate": "rotate:90", "flip-x": "flip-x", "flip-y": "flip-y", "scale": "scale:2", "tile": "tile:2:2",
+}
+
+/*
+ * Reads a comma-separated chain of morphology and geometric steps written
+ * as name:parameter:..., e.g. "crop:0:0:64:64,rotate:90,flip-x".
+ */
+func MustParseTransforms(value string) []TransformStep {
+	return mustParseSteps(value, append(append([]string{}, morphologyNames...), geometryNames...), "transform")
+}
+
+func mustParseSteps(value string, names []string, kind string) []TransformStep {
+	steps := []TransformStep{}
+	for _, step := range(strings.Split(value, ",")) {
+		splits := strings.Split(step, ":")
+		name   := strings.ToLower(splits[0])
+		known  := false
+		for _, n := range(names) {
+			known = known || name == n
+		}
+		if !known {
+			fmt.Printf("Unknown %s step \"%s\", accepted steps are \"%s\"\n", kind, splits[0], strings.Join(names, "\", \""))
+			os.Exit(1)
+		}
+		if len(splits)-1 != transformParams[name] {
+			fmt.Printf("The %s step \"%s\" needs %d parameter(s), e.g. \"%s\"\n", kind, step, transformParams[name], transformExamples[name])
+			os.Exit(1)
+		}
+		params := []int{}
+		for i, split := range(splits[1:]) {
+			param, err := strconv.Atoi(split)
+			// The corner of a crop may be at 0, every other parameter is a size
+			if err != nil || param < 0 || (param == 0 && !(name == "crop" && i < 2)) {
+				fmt.Printf("The parameters of the %s step \"%s\" must be positive integers\n", kind, step)
+				os.Exit(1)
+			}
+			params = append(params, param)
+		}
+		if name == "rotate" && params[0] != 90 && params[0] != 180 && params[0] != 270 {
+			fmt.Printf("The %s step \"%s\" must rotate by 90, 180 or 270 degrees\n", kind, step)
+			os.Exit(1)
+		}
+		steps = append(steps, TransformStep{name, params})
+	}
+	return steps
+}
+
+/*
+ * Applies the steps in order to a map and moves the scenarios with it.
+ * Cropping drops the scenarios that leave the map and tiling copies them
+ * into every tile. The optimal lengths and buckets are left as they were,
+ * see RecomputeOptimalLengths. Returns a non-nil error if a crop does not
+ * fit in the map.
+ */
+func ApplyTransforms(g [][]bool, scenarios []Scenario, steps []TransformStep) ([][]bool, []Scenario, error) {
+	scenarios = append([]Scenario{}, scenarios...)
+	for _, step := range(steps) {
+		w := len(g[0])
+		h := len(g)
+		// Where a cell goes, if it stays on the map
+		var move func(x, y int) (int, int, bool)
+		copies := [][2]int{{0, 0}}
+		switch step.Name {
+			case "crop":
+				cx, cy, cw, ch := step.Params[0], step.Params[1], step.Params[2], step.Params[3]
+				if cx + cw > w || cy + ch > h {
+					return g, scenarios, errors.New(fmt.Sprintf("The crop %d:%d:%d:%d does not fit in the %dx%d map", cx, cy, cw, ch, w, h))
+				}
+				g = CropGrid(g, cx, cy, cw, ch)
+				move = func(x, y int) (int, int, bool) {
+					return x - cx, y - cy, x >= cx && x < cx + cw && y >= cy && y < cy + ch
+				}
+			case "rotate":
+				for i := 0; i < step.Params[0] / 90; i++ {
+					g = RotateGrid(g)
+				}
+				move = func(x, y int) (int, int, bool) {
+					switch step.Params[0] {
+						case 90:
+							return h-1 - y, x, true
+						case 180:
+							return w-1 - x, h-1 - y, true
+					}
+					return y, w-1 - x, true
+				}
+			case "flip-x":
+				g = FlipGrid(g, true)
+				move = func(x, y int) (int, int, bool) {
+					return w-1 - x, y, true
+				}
+			case "flip-y":
+				g = FlipGrid(g, false)
+				move = func(x, y int) (int, int, bool) {
+					return x, h-1 - y, true
+				}
+			case "scale":
+				k := step.Params[0]
+				g = ScaleGrid(g, k)
+				move = func(x, y int) (int, int, bool) {
+					return k*x, k*y, true
+				}
+			case "tile":
+				g = TileGrid(g, step.Params[0], step.Params[1])
+				copies = [][2]int{}
+				for ty := 0; ty < step.Params[1]; ty++ {
+					for tx := 0; tx < step.Params[0]; tx++ {
+						copies = append(copies, [2]int{tx*w, ty*h})
+					}
+				}
+				move = func(x, y int) (int, int, bool) {
+					return x, y, true
+				}
+			default:
+				g = ApplyMorphology(g, []TransformStep{step})
+				continue // The cells stay where they were
+		}
+
+		moved := []Scenario{}
+		for _, offset := range(copies) {
+			for _, s := range(scenarios) {
+				sx, sy, startKept := move(s.Start.X, s.Start.Y)
+				gx, gy, goalKept  := move(s.Goal.X, s.Goal.Y)
+				if !startKept || !goalKept {
+					continue
+				}
+				s.Start  = NewNode(sx + offset[0], sy + offset[1])
+				s.Goal   = NewNode(gx + offset[0], gy + offset[1])
+				s.Width  = len(g[0])
+				s.Height = len(g)
+				moved = append(moved, s)
+			}
+		}
+		scenarios = moved
+	}
+	return g, scenarios, nil
+}
+
+// The w x h cells with their top-left cell at (x,y)
+func CropGrid(g [][]bool, x, y, w, h int) [][]bool {
+	result := make([][]bool, h)
+	for cy := 0; cy < h; cy++ {
+		result[cy] = append([]bool{}, g[y + cy][x : x + w]...)
+	}
+	return result
+}
+
+// Rotates the map by 90 degrees clockwise, so cell (x,y) moves to (h-1-y, x)
+func RotateGrid(g [][]bool) [][]bool {
+	h := len(g)
+	w := len(g[0])
+	result := newGrid(h, w, false)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			result[x][h-1 - y] = g[y][x]
+		}
+	}
+	return result
+}
+
+// Mirrors the map from left to right if horizontal, else from top to bottom
+func FlipGrid(g [][]bool, horizontal bool) [][]bool {
+	h := len(g)
+	w := len(g[0])
+	result := newGrid(w, h, false)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if horizontal {
+				result[y][w-1 - x] = g[y][x]
+			} else {
+				result[h-1 - y][x] = g[y][x]
+			}
+		}
+	}
+	return result
+}
+
+// Turns every cell into a block of k x k cells
+func ScaleGrid(g [][]bool, k int) [][]bool {
+	h := len(g)
+	w := len(g[0])
+	result := newGrid(w*k, h*k, false)
+	for y := 0; y < h*k; y++ {
+		for x := 0; x < w*k; x++ {
+			result[y][x] = g[y/k][x/k]
+		}
+	}
+	return result
+}
+
+// Repeats the map nx times from left to right and ny times from top to bottom
+func TileGrid(g [][]bool, nx, ny int) [][]bool {
+	h := len(g)
+	w := len(g[0])
+	result := newGrid(w*nx, h*ny, false)
+	for y := 0; y < h*ny; y++ {
+		for x := 0; x < w*nx; x++ {
+			result[y][x] = g[y%h][x%w]
+		}
+	}
+	return result
+}
+
+/*
+ * Sets the optimal length and bucket of every scenario again on a map and
+ * drops the scenarios whose start and goal are no longer connected. The
+ * lengths follow the movingai files whatever the options are, see
+ * movingaiLength, so they stay comparable with the lengths of the
+ * original scenarios. The scenarios are sorted by length. Returns the
+ * kept scenarios.
+ */
+func RecomputeOptimalLengths(g [][]bool, mapName string, scenarios []Scenario) []Scenario {
+	kept := []Scenario{}
+	for _, s := range(scenarios) {
+		length, found := movingaiLength(g, s.Start, s.Goal)
+		if !found {
+			continue
+		}
+		s.MapName       = mapName
+		s.OptimalLength = length
+		s.Bucket        = int(length / 4)
+		kept = append(kept, s)
+	}
+	sort.SliceStable(kept, func(i, j int) bool {
+		return kept[i].OptimalLength < kept[j].OptimalLength
+	})
+	return kept
+}
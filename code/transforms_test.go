@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+/*
+ * Checks the geometric transforms on random maps of up to 16x16 cells.
+ * Four rotations by 90 degrees and two flips give the map back, rotating
+ * by 180 degrees is flipping both ways, cropping a tiled map gives the
+ * map back and scaling and rotating commute. Random scenarios keep their
+ * cells and, in the cell-center model, the lengths of their A* paths when
+ * the map is rotated or flipped, and the optimal lengths of the scenario
+ * files stay the same in every model.
+ * Scaling a map by k makes the paths at most k times longer unless
+ * diagonal moves may cut corners in the cell-center model.
+ */
+func TestMapTransforms(t *testing.T) {
+	keepGlobals(t)
+	const epsilon = 1e-6
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		g := randomGrid(random, 16, 16, 0.5)
+		w := len(g[0])
+		h := len(g)
+		// Chains of steps that give the same map
+		equal := [][2]string{
+			{"rotate:90,rotate:90,rotate:90,rotate:90", ""},
+			{"flip-x,flip-x", ""},
+			{"rotate:180", "flip-x,flip-y"},
+			{fmt.Sprintf("tile:3:2,crop:%d:%d:%d:%d", w, h, w, h), ""},
+			{"rotate:90,scale:2", "scale:2,rotate:90"},
+		}
+		for _, chains := range(equal) {
+			results := []string{}
+			for _, chain := range(chains) {
+				result := g
+				if chain != "" {
+					result, _, _ = ApplyTransforms(g, []Scenario{}, MustParseTransforms(chain))
+				}
+				results = append(results, gridString(result))
+			}
+			if results[0] != results[1] {
+				t.Errorf("map %d: \"%s\" and \"%s\" give different maps\n%s",
+					i, chains[0], chains[1], gridString(g))
+			}
+		}
+
+		for _, model := range(allNodeModels) {
+			for _, c := range(allConnectivities) {
+				nodeModel, connectivity = model, c
+				setGrid(g)
+				scenarios, err := GenerateScenarios("test", 5, int64(i))
+				if err != nil {
+					continue // Too few open cells
+				}
+				lengths := scenarioLengths(scenarios)
+				// The chains and how much longer they make the paths at most. A
+				// diagonal move of a scaled path in the cell-center model becomes
+				// k diagonal moves, which may cut the corners of a side block.
+				cutsCorners := model == CellCenters && c == EightConnected
+				chains := []string{"rotate:90", "rotate:180", "rotate:270", "flip-x", "flip-y", "scale:2", "scale:3"}
+				stretch := []float64{1, 1, 1, 1, 1, 2, 3}
+				for n, chain := range(chains) {
+					result, moved, _ := ApplyTransforms(g, scenarios, MustParseTransforms(chain))
+					setGrid(result)
+					movedLengths := scenarioLengths(moved)
+					// The scenario files get the same lengths in any model
+					if stretch[n] == 1 {
+						before := RecomputeOptimalLengths(g, "test", scenarios)
+						after  := RecomputeOptimalLengths(result, "test", moved)
+						for j := range(before) {
+							if len(after) != len(before) || math.Abs(after[j].OptimalLength - before[j].OptimalLength) > epsilon {
+								t.Errorf("map %d, %s model, %s-connectivity: %s changes the optimal lengths of the scenario files\n%s",
+									i, nodeModelName(model), connectivityName(c), chain, gridString(g))
+								break
+							}
+						}
+					}
+					setGrid(g)
+					for j, s := range(moved) {
+						k := stretch[n]
+						problem := ""
+						if result[s.Start.Y][s.Start.X] != g[scenarios[j].Start.Y][scenarios[j].Start.X] || result[s.Goal.Y][s.Goal.X] != g[scenarios[j].Goal.Y][scenarios[j].Goal.X] {
+							problem = "the start or goal changed"
+						} else if (k == 1 && model == CellCenters && math.Abs(movedLengths[j] - lengths[j]) > epsilon) ||
+						          (k > 1 && !cutsCorners && movedLengths[j] > k*lengths[j] + epsilon) {
+							problem = fmt.Sprintf("it has length %g", movedLengths[j])
+						}
+						if problem != "" {
+							t.Errorf("map %d, %s model, %s-connectivity: %s of (%d,%d) -> (%d,%d) with length %g: %s\n%s",
+								i, nodeModelName(model), connectivityName(c), chain, scenarios[j].Start.X, scenarios[j].Start.Y, scenarios[j].Goal.X, scenarios[j].Goal.Y, lengths[j], problem, gridString(g))
+							break
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// The lengths of the A* paths of the scenarios, infinite without a path
+func scenarioLengths(scenarios []Scenario) []float64 {
+	lengths := []float64{}
+	for _, s := range(scenarios) {
+		path := AStar(s.Start, s.Goal)
+		if len(path) == 0 {
+			lengths = append(lengths, math.Inf(1))
+		} else {
+			lengths = append(lengths, PathLength(path))
+		}
+	}
+	return lengths
+}
+
+/*
+ * Every geometric step on a 3x2 map with one blocked corner cell, which
+ * shows where each step moves a cell, and on a scenario from the bottom
+ * left to the top right cell. Tiling copies the scenario, cropping off
+ * its start drops it, and a crop that does not fit fails.
+ */
+func TestTransformSteps(t *testing.T) {
+	g := gridFromRows(
+		"@..",
+		"...",
+	)
+	scenario := Scenario{"", 0, "map", 3, 2, NewNode(0, 1), NewNode(2, 0), 2}
+	cases := []struct {
+		chain     string
+		rows      []string
+		scenarios [][4]int // Start and goal of each scenario
+	}{
+		{"rotate:90", []string{".@", "..", ".."}, [][4]int{{0, 0, 1, 2}}},
+		{"rotate:180", []string{"...", "..@"}, [][4]int{{2, 0, 0, 1}}},
+		{"rotate:270", []string{"..", "..", "@."}, [][4]int{{1, 2, 0, 0}}},
+		{"flip-x", []string{"..@", "..."}, [][4]int{{2, 1, 0, 0}}},
+		{"flip-y", []string{"...", "@.."}, [][4]int{{0, 0, 2, 1}}},
+		{"scale:2", []string{"@@....", "@@....", "......", "......"}, [][4]int{{0, 2, 4, 0}}},
+		{"tile:2:1", []string{"@..@..", "......"}, [][4]int{{0, 1, 2, 0}, {3, 1, 5, 0}}},
+		{"crop:1:0:2:2", []string{"..", ".."}, [][4]int{}},
+		{"crop:0:0:2:1,rotate:90", []string{"@", "."}, [][4]int{}},
+	}
+	for _, test := range(cases) {
+		got, scenarios, err := ApplyTransforms(g, []Scenario{scenario}, MustParseTransforms(test.chain))
+		if err != nil {
+			t.Errorf("%s: %s", test.chain, err.Error())
+			continue
+		}
+		if gridString(got) != gridString(gridFromRows(test.rows...)) {
+			t.Errorf("%s gives the map\n%sexpected\n%s", test.chain, gridString(got), gridString(gridFromRows(test.rows...)))
+		}
+		if len(scenarios) != len(test.scenarios) {
+			t.Errorf("%s keeps %d scenario(s), expected %d", test.chain, len(scenarios), len(test.scenarios))
+			continue
+		}
+		for i, s := range(scenarios) {
+			expected := test.scenarios[i]
+			if s.Start != NewNode(expected[0], expected[1]) || s.Goal != NewNode(expected[2], expected[3]) ||
+			   s.Width != len(got[0]) || s.Height != len(got) {
+				t.Errorf("%s moves the scenario to (%d,%d) -> (%d,%d) on a %dx%d map, expected (%d,%d) -> (%d,%d)",
+					test.chain, s.Start.X, s.Start.Y, s.Goal.X, s.Goal.Y, s.Width, s.Height, expected[0], expected[1], expected[2], expected[3])
+			}
+		}
+	}
+	if _, _, err := ApplyTransforms(g, nil, MustParseTransforms("crop:2:0:2:2")); err == nil {
+		t.Errorf("crop:2:0:2:2 fits in the 3x2 map")
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+/*
+ * Checks that a path of an algorithm can be followed: it starts at the
+ * start and ends at the goal, and every step is a move to a neighbour or
+ * a straight line through open cells, depending on the kind of the path.
+ * An empty path is only valid if the start and the goal are not
+ * connected. The moves and lines are checked against the definitions
+ * below instead of getTraversableNodes and lineOfSight, so that the
+ * validator catches their bugs instead of repeating them.
+ */
+func ValidatePath(path []Point, start, goal Node, kind PathKind) error {
+	if len(path) == 0 {
+		if components != nil && sameComponent(start, goal) {
+			return errors.New("No path was found, but the start and the goal are connected")
+		}
+		return nil
+	}
+	ends := NodesToPoints([]Node{start, goal})
+	if path[0] != ends[0] {
+		return errors.New(fmt.Sprintf("The path starts at (%g,%g), not at the start (%g,%g)", path[0].X, path[0].Y, ends[0].X, ends[0].Y))
+	}
+	if last := path[len(path)-1]; last != ends[1] {
+		return errors.New(fmt.Sprintf("The path ends at (%g,%g), not at the goal (%g,%g)", last.X, last.Y, ends[1].X, ends[1].Y))
+	}
+	// The cells where the agent fits in the node model of the search
+	model := nodeModel
+	cellOpen := func(x, y int) bool {
+		return isCellOpen(x, y) && agentFits(x, y, model)
+	}
+	if kind == FreeLines {
+		oldConnectivity, oldModel := connectivity, nodeModel
+		defer func() {
+			connectivity, nodeModel = oldConnectivity, oldModel
+		}()
+		connectivity, nodeModel = EightConnected, GridCorners
+	}
+	for i := 0; i+1 < len(path); i++ {
+		a, b := path[i], path[i+1]
+		if kind != Moves {
+			if !pointsVisibleReference(a, b, cellOpen) {
+				return errors.New(fmt.Sprintf("Step %d from (%g,%g) to (%g,%g) has no line of sight", i+1, a.X, a.Y, b.X, b.Y))
+			}
+			continue
+		}
+		from, fromIsNode := pointNode(a)
+		to,   toIsNode   := pointNode(b)
+		if !fromIsNode || !toIsNode || !moveAllowedSpec(from, to) {
+			return errors.New(fmt.Sprintf("Step %d from (%g,%g) to (%g,%g) is not a move between neighbours", i+1, a.X, a.Y, b.X, b.Y))
+		}
+	}
+	return nil
+}
+
+// The node at a point, if there is one, see NodesToPoints
+func pointNode(p Point) (Node, bool) {
+	offset := 0.0
+	if nodeModel == CellCenters {
+		offset = 0.5
+	}
+	x, y := p.X - offset, p.Y - offset
+	return NewNode(int(math.Round(x)), int(math.Round(y))), x == math.Round(x) && y == math.Round(y)
+}
+
+/*
+ * Which moves the models should allow, written from the geometry of
+ * the moves instead of the per-direction cases in getTraversableNodes.
+ */
+func moveAllowedSpec(from, to Node) bool {
+	dx := to.X - from.X
+	dy := to.Y - from.Y
+	if math.Abs(float64(dx)) > 1 || math.Abs(float64(dy)) > 1 || (dx == 0 && dy == 0) {
+		return false
+	}
+	diagonal := dx != 0 && dy != 0
+	if diagonal && connectivity == FourConnected {
+		return false
+	}
+
+	if nodeModel == CellCenters {
+		if !isOpen(from.X, from.Y) || !isOpen(to.X, to.Y) {
+			return false
+		}
+		if !diagonal {
+			return true
+		}
+		side1 := isOpen(to.X, from.Y)
+		side2 := isOpen(from.X, to.Y)
+		if connectivity == EightConnectedNoCornerCutting {
+			return side1 && side2
+		}
+		return side1 || side2
+	}
+
+	// Corner model: the cell with the smallest coordinates that touches
+	// both nodes is the crossed cell of a diagonal move, or one of the
+	// two cells along the edge of an orthogonal move.
+	cx := int(math.Min(float64(from.X), float64(to.X)))
+	cy := int(math.Min(float64(from.Y), float64(to.Y)))
+	if !diagonal && agentSize > 0 {
+		// The agent sweeps the cells of its squares at both nodes
+		for y := cy; y < cy + int(math.Abs(float64(dy))) + agentSize; y++ {
+			for x := cx; x < cx + int(math.Abs(float64(dx))) + agentSize; x++ {
+				if !isCellOpen(x, y) {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	if !diagonal {
+		if dx == 0 {
+			return isOpen(cx-1, cy) || isOpen(cx, cy)
+		}
+		return isOpen(cx, cy-1) || isOpen(cx, cy)
+	}
+	if !isOpen(cx, cy) {
+		return false
+	}
+	if connectivity == EightConnectedNoCornerCutting {
+		for _, d := range([][2]int{{0,-1}, {1,0}, {0,1}, {-1,0}}) {
+			if !isOpen(cx+d[0], cy+d[1]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+/*
+ * Line of sight by brute force: every cell, grid edge and grid corner
+ * near the line is tested on its own with exact rational arithmetic,
+ * following the rules of segmentClear:
+ *  - The line may not enter a blocked cell.
+ *  - Where it runs along a grid edge, one of the cells on either side of
+ *    the edge must be open, like for an orthogonal move, or for a larger
+ *    agent in the corner model every cell that it covers at both ends.
+ *  - At every grid corner that it passes or ends at, it touches two side
+ *    cells without entering them, which must pass the rules of a
+ *    diagonal move between them.
+ * This is slower than segmentClear, but it tests any two points of the
+ * map, in map coordinates for both node models.
+ */
+func pointsVisibleReference(p, q Point, cellOpen func(int, int) bool) bool {
+	if connectivity == FourConnected && p.X != q.X && p.Y != q.Y {
+		return false
+	}
+	// Squeezing between two cells at a corner is a diagonal move
+	canTouch := func(side1Open, side2Open bool) bool {
+		if connectivity == EightConnectedNoCornerCutting {
+			return side1Open && side2Open
+		}
+		return nodeModel == GridCorners || side1Open || side2Open
+	}
+	// A grid edge from corner (x,y) to (x+dx,y+dy) needs an open cell on
+	// one side, and a larger agent of the corner model needs the cells of
+	// its squares at both corners
+	edgeOpen := func(x, y, dx, dy int) bool {
+		if agentSize == 0 || nodeModel != GridCorners {
+			return cellOpen(x-dy, y-dx) || cellOpen(x, y)
+		}
+		for cy := y; cy < y + dy + agentSize; cy++ {
+			for cx := x; cx < x + dx + agentSize; cx++ {
+				if !isCellOpen(cx, cy) {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	point := func(p Point) [2]*big.Rat {
+		return [2]*big.Rat{new(big.Rat).SetFloat64(p.X), new(big.Rat).SetFloat64(p.Y)}
+	}
+	a := point(p)
+	b := point(q)
+	axisAligned := p.X == q.X || p.Y == q.Y
+
+	minX := int(math.Floor(math.Min(p.X, q.X))) - 1
+	maxX := int(math.Floor(math.Max(p.X, q.X))) + 1
+	minY := int(math.Floor(math.Min(p.Y, q.Y))) - 1
+	maxY := int(math.Floor(math.Max(p.Y, q.Y))) + 1
+	for y := minY; y <= maxY; y++ {
+		// Only the cells of the row near the line, with a margin for
+		// rounding: the exact tests below decide
+		lowX, highX := minX, maxX
+		if p.Y != q.Y {
+			x1 := p.X + (float64(y)   - p.Y) * (q.X - p.X) / (q.Y - p.Y)
+			x2 := p.X + (float64(y+1) - p.Y) * (q.X - p.X) / (q.Y - p.Y)
+			lowX  = int(math.Max(float64(minX), math.Floor(math.Min(x1, x2)) - 1))
+			highX = int(math.Min(float64(maxX), math.Floor(math.Max(x1, x2)) + 1))
+		}
+		for x := lowX; x <= highX; x++ {
+			if segmentEntersCell(a, b, x, y) && !cellOpen(x, y) {
+				return false
+			}
+			// The grid edges to the east and south of the corner (x,y)
+			// that the line runs along for more than a point
+			if p.Y == q.Y && p.Y == float64(y) && math.Min(math.Max(p.X, q.X), float64(x+1)) > math.Max(math.Min(p.X, q.X), float64(x)) &&
+			   !edgeOpen(x, y, 1, 0) {
+				return false
+			}
+			if p.X == q.X && p.X == float64(x) && math.Min(math.Max(p.Y, q.Y), float64(y+1)) > math.Max(math.Min(p.Y, q.Y), float64(y)) &&
+			   !edgeOpen(x, y, 0, 1) {
+				return false
+			}
+
+			// The side cells share an edge with an entered cell around the
+			// corner, without being entered themselves
+			corner := [2]*big.Rat{big.NewRat(int64(x), 1), big.NewRat(int64(y), 1)}
+			if axisAligned || !onSegment(a, b, corner) {
+				continue
+			}
+			around := [][2]int{{x-1, y-1}, {x, y-1}, {x, y}, {x-1, y}}
+			entered := make([]bool, 4)
+			for i, c := range(around) {
+				entered[i] = segmentEntersCell(a, b, c[0], c[1])
+			}
+			sides := []bool{}
+			for i, c := range(around) {
+				if !entered[i] && (entered[(i+1)%4] || entered[(i+3)%4]) {
+					sides = append(sides, cellOpen(c[0], c[1]))
+				}
+			}
+			if len(sides) != 2 {
+				panic("Assertion failed: a line touches two side cells at a corner")
+			}
+			if !canTouch(sides[0], sides[1]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+/*
+ * Whether the segment from a to b has a point strictly inside cell (x,y).
+ * The points a + t(b-a) inside the cell form an open interval of t on
+ * each axis, which must overlap each other and the closed interval [0,1].
+ */
+func segmentEntersCell(a, b [2]*big.Rat, x, y int) bool {
+	lo := big.NewRat(0, 1)
+	hi := big.NewRat(1, 1)
+	for axis, cellStart := range([]int{x, y}) {
+		low  := big.NewRat(int64(cellStart), 1)
+		high := big.NewRat(int64(cellStart+1), 1)
+		d := new(big.Rat).Sub(b[axis], a[axis])
+		if d.Sign() == 0 {
+			if a[axis].Cmp(low) <= 0 || a[axis].Cmp(high) >= 0 {
+				return false
+			}
+			continue
+		}
+		t1 := new(big.Rat).Quo(new(big.Rat).Sub(low, a[axis]), d)
+		t2 := new(big.Rat).Quo(new(big.Rat).Sub(high, a[axis]), d)
+		if t1.Cmp(t2) > 0 {
+			t1, t2 = t2, t1
+		}
+		if t1.Cmp(lo) > 0 {
+			lo = t1
+		}
+		if t2.Cmp(hi) < 0 {
+			hi = t2
+		}
+	}
+	// A single point at t = 0 = 1 for segments of length zero
+	if a[0].Cmp(b[0]) == 0 && a[1].Cmp(b[1]) == 0 {
+		return lo.Cmp(hi) <= 0
+	}
+	return lo.Cmp(hi) < 0
+}
+
+// Whether p lies on the segment from a to b, end points included
+func onSegment(a, b, p [2]*big.Rat) bool {
+	abx := new(big.Rat).Sub(b[0], a[0])
+	aby := new(big.Rat).Sub(b[1], a[1])
+	apx := new(big.Rat).Sub(p[0], a[0])
+	apy := new(big.Rat).Sub(p[1], a[1])
+	cross := new(big.Rat).Sub(new(big.Rat).Mul(abx, apy), new(big.Rat).Mul(aby, apx))
+	if cross.Sign() != 0 {
+		return false
+	}
+	for axis := 0; axis < 2; axis++ {
+		low, high := a[axis], b[axis]
+		if low.Cmp(high) > 0 {
+			low, high = high, low
+		}
+		if p[axis].Cmp(low) < 0 || p[axis].Cmp(high) > 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+/*
+ * Runs every algorithm between random nodes on random maps of up to 10x10
+ * cells in every node model and connectivity and validates the paths as
+ * the kind that the algorithm declares. Maps without open nodes are left
+ * out, since there is nothing to select landmarks from.
+ */
+func TestValidatePathOfAlgorithms(t *testing.T) {
+	keepGlobals(t)
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 30; i++ {
+		g := randomGrid(random, 10, 10, 0.4)
+		for _, model := range(allNodeModels) {
+			for _, c := range(allConnectivities) {
+				nodeModel, connectivity = model, c
+				setGrid(g)
+				if len(componentSizes) == 0 {
+					continue
+				}
+				for _, a := range(algorithms) {
+					if a.Preprocess != nil {
+						a.Preprocess("")
+					}
+					for j := 0; j < 3; j++ {
+						start := randomNode(random)
+						goal  := randomNode(random)
+						if err := ValidatePath(a.Find(start, goal), start, goal, a.Path); err != nil {
+							t.Errorf("map %d, %s model, %s-connectivity: %s path (%d,%d) -> (%d,%d): %s\n%s",
+								i, nodeModelName(model), connectivityName(c), a.Name, start.X, start.Y, goal.X, goal.Y, err.Error(), gridString(g))
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+/*
+ * Breaks A* paths on random maps and checks that ValidatePath notices:
+ * leaving out a node must fail exactly when its neighbours on the path are
+ * not neighbours themselves, and a path that stops short or an empty path
+ * between connected nodes must fail.
+ */
+func TestValidatePathRejects(t *testing.T) {
+	keepGlobals(t)
+	random := rand.New(rand.NewSource(2))
+	for i := 0; i < 100; i++ {
+		g := randomGrid(random, 16, 16, 0.4)
+		for _, model := range(allNodeModels) {
+			for _, c := range(allConnectivities) {
+				nodeModel, connectivity = model, c
+				setGrid(g)
+				start  := randomNode(random)
+				goal   := randomNode(random)
+				path   := AStar(start, goal)
+				prefix := fmt.Sprintf("map %d, %s model, %s-connectivity, path (%d,%d) -> (%d,%d)",
+					i, nodeModelName(model), connectivityName(c), start.X, start.Y, goal.X, goal.Y)
+				if err := ValidatePath(nil, start, goal, Moves); (err == nil) == (len(path) > 0) {
+					t.Errorf("%s: the empty path gives %v, but A* found %d nodes\n%s", prefix, err, len(path), gridString(g))
+				}
+				if len(path) < 2 {
+					continue
+				}
+				if ValidatePath(NodesToPoints(path[:len(path)-1]), start, goal, Moves) == nil {
+					t.Errorf("%s: a path without the goal is valid\n%s", prefix, gridString(g))
+				}
+				for k := 1; k+1 < len(path); k++ {
+					shortcut := append(append([]Node{}, path[:k]...), path[k+1:]...)
+					err := ValidatePath(NodesToPoints(shortcut), start, goal, Moves)
+					if (err == nil) != moveAllowedSpec(path[k-1], path[k+1]) {
+						t.Errorf("%s: leaving out node %d gives %v\n%s", prefix, k, err, gridString(g))
+					}
+				}
+			}
+		}
+	}
+}
+
+/*
+ * Paths on a 2x2 map with its top right cell blocked, between grid
+ * corners. A diagonal move across the open cell is only valid with corner
+ * cutting, since without it the cells next to the move must be open and
+ * the map border is not. A move or a line through the blocked cell, a
+ * point that is not a node and wrong ends are never valid.
+ */
+func TestValidatePathCases(t *testing.T) {
+	keepGlobals(t)
+	nodeModel = GridCorners
+	setGrid(gridFromRows(
+		".@",
+		"..",
+	))
+	cases := []struct {
+		c     Connectivity
+		kind  PathKind
+		path  []Point
+		valid bool
+	}{
+		{EightConnected, Moves, []Point{{0, 0}, {1, 1}}, true},
+		{EightConnectedNoCornerCutting, Moves, []Point{{0, 0}, {1, 1}}, false},
+		{FourConnected, Moves, []Point{{0, 0}, {1, 1}}, false},
+		{FourConnected, Moves, []Point{{0, 0}, {0, 1}, {1, 1}}, true},
+		{EightConnected, Moves, []Point{{1, 0}, {2, 1}}, false},
+		{EightConnected, Moves, []Point{{1, 0}, {1, 1}, {2, 1}}, true},
+		{EightConnected, Moves, []Point{{0, 0}, {0.5, 0.5}, {1, 1}}, false},
+		{EightConnected, Lines, []Point{{0, 0}, {1, 2}}, true},
+		{EightConnected, Lines, []Point{{0, 0}, {2, 1}}, false},
+		{EightConnected, Lines, []Point{{0, 0}, {1, 1}, {2, 1}}, true},
+		{EightConnected, Lines, []Point{{0, 2}, {2, 0}}, false},
+		{FourConnected, FreeLines, []Point{{0, 0}, {1, 1}}, true},
+	}
+	for _, test := range(cases) {
+		connectivity = test.c
+		start, _ := pointNode(test.path[0])
+		goal,  _ := pointNode(test.path[len(test.path)-1])
+		err := ValidatePath(test.path, start, goal, test.kind)
+		if (err == nil) != test.valid {
+			t.Errorf("%s-connectivity: the path %v of kind %d gives %v", connectivityName(test.c), test.path, test.kind, err)
+		}
+	}
+	connectivity = EightConnected
+	path := []Point{{0, 0}, {0, 1}, {1, 1}}
+	if ValidatePath(path, NewNode(0, 0), NewNode(1, 2), Moves) == nil || ValidatePath(path, NewNode(0, 1), NewNode(1, 1), Moves) == nil {
+		t.Errorf("the path %v is valid with other ends", path)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+)
+
+/*
+ * Writes a scenarios file according to this format:
+ * https://movingai.com/benchmarks/formats.html
+ * Returns a non-nil error if something goes wrong.
+ */
+func SaveScenarios(scenarios []Scenario, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return errors.New("Could not create file "+err.Error())
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	fmt.Fprintln(writer, "version 1")
+	for _, s := range(scenarios) {
+		fmt.Fprintf(writer, "%d\t%s\t%d\t%d\t%d\t%d\t%d\t%d\t%.8f\n",
+			s.Bucket, s.MapName, s.Width, s.Height,
+			s.Start.X, s.Start.Y, s.Goal.X, s.Goal.Y, s.OptimalLength)
+	}
+	err = writer.Flush()
+	if err != nil {
+		return errors.New("Could not write file "+err.Error())
+	}
+	return nil
+}
+
+/*
+ * Writes a map file according to this format:
+ * https://movingai.com/benchmarks/formats.html
+ * Blocked cells are written as '@' and open cells as '.'.
+ * Returns a non-nil error if something goes wrong.
+ */
+func SaveMap(g [][]bool, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return errors.New("Could not create file "+err.Error())
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	fmt.Fprintln(writer, "type octile")
+	fmt.Fprintf(writer, "height %d\n", len(g))
+	fmt.Fprintf(writer, "width %d\n", len(g[0]))
+	fmt.Fprintln(writer, "map")
+	for _, row := range(g) {
+		line := make([]byte, len(row))
+		for x, blocked := range(row) {
+			if blocked {
+				line[x] = '@'
+			} else {
+				line[x] = '.'
+			}
+		}
+		writer.Write(line)
+		writer.WriteByte('\n')
+	}
+	err = writer.Flush()
+	if err != nil {
+		return errors.New("Could not write file "+err.Error())
+	}
+	return nil
+}
+
+/*
+ * Writes an HPA* graph: a header with the map hash and the settings the
+ * graph was built with, then one edge per line as
+ * from_x from_y to_x to_y cost
+ * Returns a non-nil error if something goes wrong.
+ */
+func SaveHPAGraph(graph *HPAGraph, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return errors.New("Could not create file "+err.Error())
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	fmt.Fprintln(writer, "hpa 1")
+	fmt.Fprintf(writer, "hash %d\n", graph.MapHash)
+	fmt.Fprintf(writer, "cluster-size %d\n", graph.ClusterSize)
+	fmt.Fprintf(writer, "model %d\n", graph.Model)
+	fmt.Fprintf(writer, "connectivity %d\n", graph.Connectivity)
+	for from, edges := range(graph.Edges) {
+		for _, e := range(edges) {
+			fmt.Fprintf(writer, "%d %d %d %d %.8f\n", from.X, from.Y, e.To.X, e.To.Y, e.Cost)
+		}
+	}
+	err = writer.Flush()
+	if err != nil {
+		return errors.New("Could not write file "+err.Error())
+	}
+	return nil
+}
+
+/*
+ * Writes landmarks in a little-endian binary format: the magic "ALT1",
+ * the map hash (uint64), then the node model, connectivity, width, height
+ * and number of landmarks (int32 each), the x and y of each landmark
+ * (int32) and finally their distance tables (float32).
+ * Returns a non-nil error if something goes wrong.
+ */
+func SaveLandmarks(l *Landmarks, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return errors.New("Could not create file "+err.Error())
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	header := []interface{}{[4]byte{'A', 'L', 'T', '1'}, l.MapHash,
+		int32(l.Model), int32(l.Connectivity), int32(l.Width), int32(l.Height), int32(len(l.Nodes))}
+	for _, n := range(l.Nodes) {
+		header = append(header, int32(n.X), int32(n.Y))
+	}
+	for _, value := range(header) {
+		err = binary.Write(writer, binary.LittleEndian, value)
+		if err != nil {
+			return errors.New("Could not write file "+err.Error())
+		}
+	}
+	for _, distances := range(l.Distances) {
+		err = binary.Write(writer, binary.LittleEndian, distances)
+		if err != nil {
+			return errors.New("Could not write file "+err.Error())
+		}
+	}
+	err = writer.Flush()
+	if err != nil {
+		return errors.New("Could not write file "+err.Error())
+	}
+	return nil
+}